@@ -0,0 +1,245 @@
+/*
+Artifact package keeps the binary of every successful build of a process, so it can be rolled back
+to a previous one:
+
+- <SysFolder>/<name>/builds/<version>
+- <SysFolder>/<name>/builds/history.toml
+
+The process always runs <SysFolder>/<name>/<name>, a link to the active build. The oldest builds
+are removed past the number kept, except the active one.
+*/
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/struCoder/pmgo/lib/utils"
+)
+
+// DefaultKeep is the number of builds kept when none is configured.
+const DefaultKeep = 5
+
+// mutex guards the read, update and write of the history files.
+var mutex sync.Mutex
+
+// Artifact is the binary of a successful build.
+type Artifact struct {
+	Version    string    // Version identifies the build, such as 20261018-113513-3f2a1c9.
+	BuiltAt    time.Time // BuiltAt is when the build succeeded.
+	Commit     string    // Commit is the git commit of the source, suffixed with -dirty on local changes. Empty outside git.
+	SourceHash string    // SourceHash is the sha256 of the .go files, go.mod and go.sum of the source.
+	Build      string    // Build is the build command line.
+}
+
+// History is the list of builds of a process, oldest first.
+type History struct {
+	Active    string      // Active is the version the process binary links to.
+	Artifacts []*Artifact // Artifacts are the builds kept.
+}
+
+// Dir will return the folder the builds of the process folder path are kept on.
+func Dir(path string) string {
+	return filepath.Join(path, "builds")
+}
+
+// Describe will return the artifact of a build of source with the build command line, identified
+// by the current time and its git commit, or source hash outside git.
+func Describe(source string, build string) *Artifact {
+	artifact := &Artifact{
+		BuiltAt:    time.Now(),
+		Commit:     gitCommit(source),
+		SourceHash: sourceHash(source),
+		Build:      build,
+	}
+	id := artifact.Commit
+	if id == "" {
+		id = artifact.SourceHash
+	}
+	artifact.Version = artifact.BuiltAt.Format("20060102-150405")
+	if len(id) >= 7 {
+		artifact.Version += "-" + id[:7]
+	}
+	return artifact
+}
+
+// Add will move binary into dir as artifact, link binPath to it and remove the oldest builds past keep.
+// Returns an error in case there's any.
+func Add(dir string, binary string, artifact *Artifact, binPath string, keep int) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	history, err := load(dir)
+	if err != nil {
+		return err
+	}
+	// builds on the same second of the same source
+	version := artifact.Version
+	for i := 2; history.Find(artifact.Version) != nil; i++ {
+		artifact.Version = version + "." + strconv.Itoa(i)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	if err := os.Rename(binary, filepath.Join(dir, artifact.Version)); err != nil {
+		return err
+	}
+	history.Artifacts = append(history.Artifacts, artifact)
+	if err := link(filepath.Join(dir, artifact.Version), binPath); err != nil {
+		return err
+	}
+	history.Active = artifact.Version
+	history.prune(dir, keep)
+	return save(dir, history)
+}
+
+// Activate will link binPath to the build version of dir, making it the active one.
+// Returns an error in case there's any.
+func Activate(dir string, version string, binPath string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	history, err := load(dir)
+	if err != nil {
+		return err
+	}
+	if history.Find(version) == nil {
+		return fmt.Errorf("unknown build %s", version)
+	}
+	if err := link(filepath.Join(dir, version), binPath); err != nil {
+		return err
+	}
+	history.Active = version
+	return save(dir, history)
+}
+
+// Load will read the history of the builds of dir. It is empty when nothing was built yet.
+// Returns a tuple with the history and an error in case there's any.
+func Load(dir string) (*History, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return load(dir)
+}
+
+// Find will return the build version, or nil if it is not kept.
+func (history *History) Find(version string) *Artifact {
+	for _, artifact := range history.Artifacts {
+		if artifact.Version == version {
+			return artifact
+		}
+	}
+	return nil
+}
+
+// Previous will return the build before the active one, or nil if there is none.
+func (history *History) Previous() *Artifact {
+	for i, artifact := range history.Artifacts {
+		if artifact.Version == history.Active && i > 0 {
+			return history.Artifacts[i-1]
+		}
+	}
+	return nil
+}
+
+// prune will remove the oldest builds past keep, except the active one.
+func (history *History) prune(dir string, keep int) {
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	for excess := len(history.Artifacts) - keep; excess > 0; excess-- {
+		for i, artifact := range history.Artifacts {
+			if artifact.Version == history.Active {
+				continue
+			}
+			os.Remove(filepath.Join(dir, artifact.Version))
+			history.Artifacts = append(history.Artifacts[:i], history.Artifacts[i+1:]...)
+			break
+		}
+	}
+}
+
+func load(dir string) (*History, error) {
+	history := &History{}
+	historyPath := filepath.Join(dir, "history.toml")
+	if _, err := os.Stat(historyPath); os.IsNotExist(err) {
+		return history, nil
+	}
+	return history, utils.SafeReadTomlFile(historyPath, history)
+}
+
+func save(dir string, history *History) error {
+	return utils.SafeWriteTomlFile(history, filepath.Join(dir, "history.toml"))
+}
+
+// link will atomically replace binPath with a hard link to artifact, or a copy of it when links
+// are not supported. The link is made on a folder of its own, so it never clashes with a build.
+func link(artifact string, binPath string) error {
+	tmpDir, err := ioutil.TempDir(filepath.Dir(binPath), ".link-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpPath := filepath.Join(tmpDir, filepath.Base(binPath))
+	if err := os.Link(artifact, tmpPath); err != nil {
+		if err := utils.CopyFile(artifact, tmpPath); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, binPath)
+}
+
+func gitCommit(source string) string {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = source
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	commit := strings.TrimSpace(string(output))
+	cmd = exec.Command("git", "status", "--porcelain")
+	cmd.Dir = source
+	if status, err := cmd.Output(); err == nil && len(strings.TrimSpace(string(status))) > 0 {
+		commit += "-dirty"
+	}
+	return commit
+}
+
+// sourceHash will hash the path and content of the .go files, go.mod and go.sum under source.
+func sourceHash(source string) string {
+	hash := sha256.New()
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if name := info.Name(); path != source && (name == ".git" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name := info.Name(); filepath.Ext(name) != ".go" && name != "go.mod" && name != "go.sum" {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		rel, _ := filepath.Rel(source, path)
+		io.WriteString(hash, rel+"\x00")
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,103 @@
+package artifact
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPrevious(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []string
+		active   string
+		want     string // want is the previous version, empty for none.
+	}{
+		{name: "empty", versions: nil, active: "", want: ""},
+		{name: "single", versions: []string{"v1"}, active: "v1", want: ""},
+		{name: "newest active", versions: []string{"v1", "v2", "v3"}, active: "v3", want: "v2"},
+		{name: "rolled back", versions: []string{"v1", "v2", "v3"}, active: "v2", want: "v1"},
+		{name: "oldest active", versions: []string{"v1", "v2", "v3"}, active: "v1", want: ""},
+		{name: "unknown active", versions: []string{"v1", "v2"}, active: "v9", want: ""},
+	}
+	for _, test := range tests {
+		history := newHistory(test.versions, test.active)
+		got := ""
+		if previous := history.Previous(); previous != nil {
+			got = previous.Version
+		}
+		if got != test.want {
+			t.Errorf("%s: Previous() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []string
+		active   string
+		keep     int
+		want     []string
+	}{
+		{name: "under keep", versions: []string{"v1", "v2"}, active: "v2", keep: 3, want: []string{"v1", "v2"}},
+		{name: "at keep", versions: []string{"v1", "v2", "v3"}, active: "v3", keep: 3, want: []string{"v1", "v2", "v3"}},
+		{name: "oldest removed", versions: []string{"v1", "v2", "v3", "v4"}, active: "v4", keep: 2, want: []string{"v3", "v4"}},
+		{name: "active kept", versions: []string{"v1", "v2", "v3", "v4"}, active: "v1", keep: 2, want: []string{"v1", "v4"}},
+		{name: "keep one", versions: []string{"v1", "v2", "v3"}, active: "v2", keep: 1, want: []string{"v2"}},
+		{
+			name:     "default keep",
+			versions: []string{"v1", "v2", "v3", "v4", "v5", "v6", "v7"},
+			active:   "v7",
+			keep:     0,
+			want:     []string{"v3", "v4", "v5", "v6", "v7"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "artifact")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			for _, version := range test.versions {
+				if err := ioutil.WriteFile(filepath.Join(dir, version), nil, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			history := newHistory(test.versions, test.active)
+			history.prune(dir, test.keep)
+			if got := versions(history); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("kept %v, want %v", got, test.want)
+			}
+			kept := make(map[string]bool)
+			for _, version := range test.want {
+				kept[version] = true
+			}
+			// removed builds lose their binary too
+			for _, version := range test.versions {
+				if _, err := os.Stat(filepath.Join(dir, version)); (err == nil) != kept[version] {
+					t.Errorf("binary of %s exists = %v, want %v", version, err == nil, kept[version])
+				}
+			}
+		})
+	}
+}
+
+func newHistory(versions []string, active string) *History {
+	history := &History{Active: active}
+	for _, version := range versions {
+		history.Artifacts = append(history.Artifacts, &Artifact{Version: version})
+	}
+	return history
+}
+
+func versions(history *History) []string {
+	versions := []string{}
+	for _, artifact := range history.Artifacts {
+		versions = append(versions, artifact.Version)
+	}
+	return versions
+}
@@ -0,0 +1,126 @@
+/*
+Build package describes how the Go source of a process is compiled, so it is rebuilt the same way
+on every later build:
+
+- pmgo start ./svc svc --package ./cmd/server --tags netgo --ldflags="-X main.version=1.0.0"
+
+The build runs from the source directory, so packages of a module resolve like on go build.
+*/
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultToolchain is the go binary used when Spec does not set one.
+const DefaultToolchain = "go"
+
+// Spec is how the source of a process is built. The zero value builds the source directory.
+type Spec struct {
+	Package   string            // Package is the package built, relative to the source directory (ex: ./cmd/server). Defaults to the source directory.
+	Tags      []string          // Tags are the build tags.
+	Ldflags   string            // Ldflags are the linker flags (ex: -s -w -X main.version=1.0.0).
+	Gcflags   string            // Gcflags are the compiler flags.
+	Trimpath  bool              // Trimpath removes file system paths from the binary.
+	Race      bool              // Race enables the race detector.
+	Env       map[string]string // Env is added to the daemon environment while building (ex: CGO_ENABLED=0, GOFLAGS=-mod=vendor).
+	Toolchain string            // Toolchain is the go binary, by name on the daemon PATH or by path (ex: go1.22.4). Defaults to go.
+}
+
+// IsZero will return true if spec changes nothing from a plain go build.
+func (spec Spec) IsZero() bool {
+	return spec.Package == "" && len(spec.Tags) == 0 && spec.Ldflags == "" && spec.Gcflags == "" &&
+		!spec.Trimpath && !spec.Race && len(spec.Env) == 0 && spec.Toolchain == ""
+}
+
+// Validate will return an error if an environment variable name is invalid.
+func (spec Spec) Validate() error {
+	for key := range spec.Env {
+		if key == "" || strings.ContainsAny(key, "= ") {
+			return fmt.Errorf("invalid build environment variable %q", key)
+		}
+	}
+	return nil
+}
+
+// Args will return the go build arguments writing the binary to output.
+func (spec Spec) Args(output string) []string {
+	return append([]string{"build", "-o", output}, spec.flags()...)
+}
+
+// Command will return the command building the source directory into output.
+func (spec Spec) Command(source string, output string) *exec.Cmd {
+	cmd := exec.Command(spec.toolchain(), spec.Args(output)...)
+	cmd.Dir = source
+	// later entries win, so Env overrides the daemon environment
+	cmd.Env = os.Environ()
+	for _, key := range spec.envKeys() {
+		cmd.Env = append(cmd.Env, key+"="+spec.Env[key])
+	}
+	return cmd
+}
+
+// String will describe spec as a command line, such as "CGO_ENABLED=0 go build -tags netgo ./cmd/server".
+func (spec Spec) String() string {
+	parts := []string{}
+	for _, key := range spec.envKeys() {
+		parts = append(parts, quote(key+"="+spec.Env[key]))
+	}
+	parts = append(parts, quote(spec.toolchain()), "build")
+	for _, flag := range spec.flags() {
+		parts = append(parts, quote(flag))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (spec Spec) flags() []string {
+	flags := []string{}
+	if len(spec.Tags) > 0 {
+		flags = append(flags, "-tags", strings.Join(spec.Tags, ","))
+	}
+	if spec.Ldflags != "" {
+		flags = append(flags, "-ldflags", spec.Ldflags)
+	}
+	if spec.Gcflags != "" {
+		flags = append(flags, "-gcflags", spec.Gcflags)
+	}
+	if spec.Trimpath {
+		flags = append(flags, "-trimpath")
+	}
+	if spec.Race {
+		flags = append(flags, "-race")
+	}
+	pkg := spec.Package
+	if pkg == "" {
+		pkg = "."
+	}
+	return append(flags, pkg)
+}
+
+func (spec Spec) toolchain() string {
+	if spec.Toolchain == "" {
+		return DefaultToolchain
+	}
+	return spec.Toolchain
+}
+
+func (spec Spec) envKeys() []string {
+	keys := []string{}
+	for key := range spec.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func quote(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\"'") {
+		return strconv.Quote(value)
+	}
+	return value
+}
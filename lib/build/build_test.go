@@ -0,0 +1,68 @@
+package build
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		want []string
+	}{
+		{name: "zero", spec: Spec{}, want: []string{"build", "-o", "/tmp/app", "."}},
+		{
+			name: "package",
+			spec: Spec{Package: "./cmd/server"},
+			want: []string{"build", "-o", "/tmp/app", "./cmd/server"},
+		},
+		{
+			name: "every flag",
+			spec: Spec{
+				Package:  "./cmd/server",
+				Tags:     []string{"netgo", "osusergo"},
+				Ldflags:  "-s -w -X main.version=1.0.0",
+				Gcflags:  "all=-N -l",
+				Trimpath: true,
+				Race:     true,
+			},
+			want: []string{"build", "-o", "/tmp/app", "-tags", "netgo,osusergo", "-ldflags", "-s -w -X main.version=1.0.0",
+				"-gcflags", "all=-N -l", "-trimpath", "-race", "./cmd/server"},
+		},
+	}
+	for _, test := range tests {
+		if got := test.spec.Args("/tmp/app"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got args %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	spec := Spec{Env: map[string]string{"GOOS": "linux", "CGO_ENABLED": "0"}, Toolchain: "/usr/local/go1.22/bin/go", Tags: []string{"netgo"}}
+	cmd := spec.Command("/src/app", "/tmp/app")
+	if cmd.Path != spec.Toolchain || cmd.Dir != "/src/app" {
+		t.Fatalf("got command %s on %s", cmd.Path, cmd.Dir)
+	}
+	if want := []string{"CGO_ENABLED=0", "GOOS=linux"}; !reflect.DeepEqual(cmd.Env[len(cmd.Env)-2:], want) {
+		t.Fatalf("got build environment ending with %q, want %q", cmd.Env[len(cmd.Env)-2:], want)
+	}
+	if want := `CGO_ENABLED=0 GOOS=linux /usr/local/go1.22/bin/go build -tags netgo .`; spec.String() != want {
+		t.Fatalf("got %q, want %q", spec.String(), want)
+	}
+	if got := (Spec{Ldflags: "-s -w"}).String(); !strings.HasSuffix(got, `-ldflags "-s -w" .`) {
+		t.Fatalf("got %q, the ldflags must be quoted", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, key := range []string{"", "A=B", "A B"} {
+		if err := (Spec{Env: map[string]string{key: "1"}}).Validate(); err == nil {
+			t.Errorf("build environment variable %q is valid", key)
+		}
+	}
+	if err := (Spec{Env: map[string]string{"CGO_ENABLED": "0"}}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
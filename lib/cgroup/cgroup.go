@@ -0,0 +1,121 @@
+/*
+Cgroup package enforces hard resource limits on processes with cgroup v2. The daemon must run on
+a delegated cgroup, such as the one of the pmgo startup systemd unit. Setup moves the daemon to a
+leaf of it, and every process gets its own child cgroup:
+
+- <daemon cgroup>/daemon
+- <daemon cgroup>/app.<name>
+
+When the daemon cgroup can't be used, limits are not enforced.
+*/
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/struCoder/pmgo/lib/utils"
+)
+
+// cpuPeriod is the cpu.max period, in microseconds, CPUMax quotas are computed on.
+const cpuPeriod = 100000
+
+// ErrDisabled is returned when Setup could not prepare the daemon cgroup.
+var ErrDisabled = errors.New("cgroups are disabled")
+
+// Limits are the hard resource limits of a process. Zero values are unlimited.
+type Limits struct {
+	CPUMax    string // CPUMax is the CPUs the process may use, such as 0.5 or 150%, or a raw cpu.max "quota period".
+	MemoryMax int64  // MemoryMax is the memory in bytes above which the process is reclaimed, then OOM killed.
+	PidsMax   int    // PidsMax is the maximum number of processes and threads.
+	IOWeight  int    // IOWeight is the proportional IO weight, from 1 to 10000. Defaults to 100.
+}
+
+// Usage is the resource usage of a process cgroup.
+type Usage struct {
+	Memory  int64         // Memory is memory.current, in bytes.
+	CPU     time.Duration // CPU is the CPU time used since the cgroup was created.
+	Pids    int           // Pids is the number of processes and threads.
+	IORead  int64         // IORead are the bytes read from block devices.
+	IOWrite int64         // IOWrite are the bytes written to block devices.
+}
+
+// Enabled will return true if limits sets any limit.
+func (limits Limits) Enabled() bool {
+	return limits != Limits{}
+}
+
+// Validate will return an error if a limit is out of range.
+func (limits Limits) Validate() error {
+	if _, err := limits.cpuMax(); err != nil {
+		return err
+	}
+	if limits.MemoryMax < 0 {
+		return errors.New("memory max can't be negative")
+	}
+	if limits.PidsMax < 0 {
+		return errors.New("pids max can't be negative")
+	}
+	if limits.IOWeight != 0 && (limits.IOWeight < 1 || limits.IOWeight > 10000) {
+		return errors.New("io weight must be between 1 and 10000")
+	}
+	return nil
+}
+
+// String will describe limits, such as "cpu 0.5, memory 512MB, pids 100, io weight 200".
+func (limits Limits) String() string {
+	described := []string{}
+	if limits.CPUMax != "" {
+		described = append(described, "cpu "+limits.CPUMax)
+	}
+	if limits.MemoryMax > 0 {
+		described = append(described, "memory "+utils.FormatMemory(int(limits.MemoryMax)))
+	}
+	if limits.PidsMax > 0 {
+		described = append(described, "pids "+strconv.Itoa(limits.PidsMax))
+	}
+	if limits.IOWeight > 0 {
+		described = append(described, "io weight "+strconv.Itoa(limits.IOWeight))
+	}
+	return strings.Join(described, ", ")
+}
+
+// String will describe usage, such as "memory 12MB, cpu 1.5s, pids 3, io 1MB read 2MB written".
+func (usage Usage) String() string {
+	return fmt.Sprintf("memory %s, cpu %s, pids %d, io %s read %s written",
+		utils.FormatMemory(int(usage.Memory)), usage.CPU, usage.Pids,
+		utils.FormatMemory(int(usage.IORead)), utils.FormatMemory(int(usage.IOWrite)))
+}
+
+// cpuMax will convert CPUMax to the cpu.max file format.
+// Returns a tuple with the content and an error in case there's any.
+func (limits Limits) cpuMax() (string, error) {
+	value := strings.TrimSpace(limits.CPUMax)
+	if value == "" {
+		return "max", nil
+	}
+	if fields := strings.Fields(value); len(fields) == 2 {
+		// raw "quota period"
+		if _, err := strconv.Atoi(fields[1]); err == nil {
+			return value, nil
+		}
+	}
+	divisor := 1.0
+	if strings.HasSuffix(value, "%") {
+		value = strings.TrimSuffix(value, "%")
+		divisor = 100
+	}
+	cpus, err := strconv.ParseFloat(value, 64)
+	if err != nil || cpus <= 0 {
+		return "", fmt.Errorf("invalid cpu max %q, use a number of CPUs such as 0.5 or a percentage such as 150%%", limits.CPUMax)
+	}
+	quota := int(cpus / divisor * cpuPeriod)
+	if quota < 1000 {
+		// the kernel refuses quotas under 1ms
+		quota = 1000
+	}
+	return fmt.Sprintf("%d %d", quota, cpuPeriod), nil
+}
@@ -0,0 +1,343 @@
+//go:build linux
+// +build linux
+
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// controllers are the cgroup v2 controllers enabled for the processes, when available.
+var controllers = []string{"cpu", "memory", "pids", "io"}
+
+var (
+	mutex sync.Mutex
+	root  string // root is the daemon cgroup directory. Empty when Setup failed or was not called.
+)
+
+// Setup will prepare the daemon cgroup so processes can be placed on child cgroups: the daemon
+// is moved to a leaf, since cgroup v2 only enables controllers on cgroups without processes.
+// It must be called once, before any process is started.
+// Returns an error, in which case limits are not enforced, in case there's any.
+func Setup() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	mount, err := mountpoint()
+	if err != nil {
+		return err
+	}
+	current, err := ownCgroup()
+	if err != nil {
+		return err
+	}
+	if current == "/" {
+		return errors.New("the daemon runs on the root cgroup, start it on a delegated cgroup such as the pmgo startup unit")
+	}
+	dir := filepath.Join(mount, current)
+	pids, err := readPids(dir)
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		// the daemon and, while it is starting, the process that forked it
+		if pid != os.Getpid() && pid != os.Getppid() {
+			return fmt.Errorf("cgroup %s is shared with other processes, start the daemon on a delegated cgroup such as the pmgo startup unit", current)
+		}
+	}
+	leaf := filepath.Join(dir, "daemon")
+	if err := os.MkdirAll(leaf, 0755); err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		if err := writeFile(leaf, "cgroup.procs", strconv.Itoa(pid)); err != nil && pid == os.Getpid() {
+			return err
+		}
+	}
+	available, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	enable := []string{}
+	for _, controller := range controllers {
+		for _, field := range strings.Fields(string(available)) {
+			if field == controller {
+				enable = append(enable, "+"+controller)
+			}
+		}
+	}
+	if len(enable) > 0 {
+		if err := writeFile(dir, "cgroup.subtree_control", strings.Join(enable, " ")); err != nil {
+			return err
+		}
+	}
+	root = dir
+	return nil
+}
+
+// Active will return true if Setup succeeded.
+func Active() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return root != ""
+}
+
+// Prepare will create the cgroup of the process name and write limits on it. Unset limits are
+// written as unlimited, so removed limits are lifted on the next start.
+// Returns an error, ErrDisabled when Setup failed, in case there's any. The cgroup exists unless
+// creating it failed, even when a limit could not be written.
+func Prepare(name string, limits Limits) error {
+	dir, err := cgroupDir(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	cpuMax, err := limits.cpuMax()
+	if err != nil {
+		return err
+	}
+	files := []struct {
+		name  string
+		value string
+		set   bool
+	}{
+		{"cpu.max", cpuMax, limits.CPUMax != ""},
+		{"memory.max", maxValue(limits.MemoryMax), limits.MemoryMax > 0},
+		{"pids.max", maxValue(int64(limits.PidsMax)), limits.PidsMax > 0},
+		{"io.weight", "default " + strconv.Itoa(defaultIOWeight(limits.IOWeight)), limits.IOWeight > 0},
+	}
+	var limitErr error
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(dir, file.name)); os.IsNotExist(err) {
+			if file.set && limitErr == nil {
+				limitErr = fmt.Errorf("%s is not available on cgroup %s", file.name, dir)
+			}
+			continue
+		}
+		if err := writeFile(dir, file.name, file.value); err != nil && limitErr == nil {
+			limitErr = err
+		}
+	}
+	return limitErr
+}
+
+// Open will open the cgroup directory of the process name, created by Prepare, so a process can
+// be started inside it with SysProcAttr.CgroupFD. The caller must close it.
+// Returns a tuple with the directory and an error in case there's any.
+func Open(name string) (*os.File, error) {
+	dir, err := cgroupDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(dir, os.O_RDONLY|syscall.O_DIRECTORY, 0)
+}
+
+// Move will move pid to the cgroup of the process name, created by Prepare. Whatever pid forked
+// before is not moved along, so processes should rather be started inside the cgroup with Open.
+// Returns an error in case there's any.
+func Move(name string, pid int) error {
+	dir, err := cgroupDir(name)
+	if err != nil {
+		return err
+	}
+	return writeFile(dir, "cgroup.procs", strconv.Itoa(pid))
+}
+
+// Kill will SIGKILL every process left on the cgroup of the process name, such as children that
+// left the process group.
+// Returns an error in case there's any.
+func Kill(name string) error {
+	dir, err := cgroupDir(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	// cgroup.kill needs linux 5.14, older kernels get the processes killed one by one
+	if _, err := os.Stat(filepath.Join(dir, "cgroup.kill")); err == nil {
+		return writeFile(dir, "cgroup.kill", "1")
+	}
+	pids, err := readPids(dir)
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+	return nil
+}
+
+// Remove will remove the cgroup of the process name. Its processes must be dead, it waits a
+// moment for the ones just killed by Kill to exit.
+// Returns an error in case there's any.
+func Remove(name string) error {
+	dir, err := cgroupDir(name)
+	if err != nil {
+		return err
+	}
+	for retries := 0; ; retries++ {
+		err := os.Remove(dir)
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		if !errors.Is(err, syscall.EBUSY) || retries == 20 {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// ReadUsage will read the resource usage of the process name from its cgroup files.
+// Returns a tuple with the usage and an error in case there's any.
+func ReadUsage(name string) (*Usage, error) {
+	dir, err := cgroupDir(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	usage := &Usage{}
+	usage.Memory, _ = readInt(dir, "memory.current")
+	pids, _ := readInt(dir, "pids.current")
+	usage.Pids = int(pids)
+	if stat, err := readKeys(dir, "cpu.stat"); err == nil {
+		usage.CPU = time.Duration(stat["usage_usec"]) * time.Microsecond
+	}
+	// io.stat has a line per device: "8:0 rbytes=1 wbytes=2 ..."
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "io.stat")); err == nil {
+		for _, field := range strings.Fields(string(content)) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value, _ := strconv.ParseInt(kv[1], 10, 64)
+			switch kv[0] {
+			case "rbytes":
+				usage.IORead += value
+			case "wbytes":
+				usage.IOWrite += value
+			}
+		}
+	}
+	return usage, nil
+}
+
+func cgroupDir(name string) (string, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if root == "" {
+		return "", ErrDisabled
+	}
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("invalid cgroup name %s", name)
+	}
+	return filepath.Join(root, "app."+name), nil
+}
+
+// mountpoint will return where the cgroup v2 hierarchy is mounted, reading /proc/self/mountinfo.
+func mountpoint() (string, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 42 32 0:38 / /sys/fs/cgroup rw,relatime - cgroup2 cgroup2 rw
+		parts := strings.SplitN(scanner.Text(), " - ", 2)
+		fields := strings.Fields(parts[0])
+		if len(parts) == 2 && len(fields) >= 5 && strings.HasPrefix(parts[1], "cgroup2 ") {
+			return fields[4], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("cgroup v2 is not mounted")
+}
+
+// ownCgroup will return the cgroup v2 path of the daemon, reading /proc/self/cgroup.
+func ownCgroup() (string, error) {
+	content, err := ioutil.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	return "", errors.New("the daemon is not on a cgroup v2")
+}
+
+func readPids(dir string) ([]int, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	pids := []int{}
+	for _, field := range strings.Fields(string(content)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func readInt(dir string, name string) (int64, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// readKeys will read a flat keyed file such as cpu.stat.
+func readKeys(dir string, name string) (map[string]int64, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]int64)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			keys[fields[0]], _ = strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return keys, nil
+}
+
+func writeFile(dir string, name string, value string) error {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %s", filepath.Join(dir, name), err)
+	}
+	return nil
+}
+
+func maxValue(value int64) string {
+	if value <= 0 {
+		return "max"
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+func defaultIOWeight(weight int) int {
+	if weight <= 0 {
+		return 100
+	}
+	return weight
+}
@@ -0,0 +1,49 @@
+//go:build !linux
+// +build !linux
+
+package cgroup
+
+import (
+	"errors"
+	"os"
+)
+
+// Setup is only supported on linux, so limits are not enforced elsewhere.
+func Setup() error {
+	return errors.New("cgroups are only supported on linux")
+}
+
+// Active will always return false, as cgroups are only supported on linux.
+func Active() bool {
+	return false
+}
+
+// Prepare is only supported on linux.
+func Prepare(name string, limits Limits) error {
+	return ErrDisabled
+}
+
+// Open is only supported on linux.
+func Open(name string) (*os.File, error) {
+	return nil, ErrDisabled
+}
+
+// Move is only supported on linux.
+func Move(name string, pid int) error {
+	return ErrDisabled
+}
+
+// Kill is only supported on linux.
+func Kill(name string) error {
+	return ErrDisabled
+}
+
+// Remove is only supported on linux.
+func Remove(name string) error {
+	return ErrDisabled
+}
+
+// ReadUsage is only supported on linux.
+func ReadUsage(name string) (*Usage, error) {
+	return nil, ErrDisabled
+}
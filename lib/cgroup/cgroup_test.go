@@ -0,0 +1,47 @@
+package cgroup
+
+import "testing"
+
+func TestCPUMax(t *testing.T) {
+	tests := []struct {
+		cpuMax string
+		want   string
+		err    bool
+	}{
+		{cpuMax: "", want: "max"},
+		{cpuMax: "1", want: "100000 100000"},
+		{cpuMax: "0.5", want: "50000 100000"},
+		{cpuMax: " 2 ", want: "200000 100000"},
+		{cpuMax: "150%", want: "150000 100000"},
+		{cpuMax: "50%", want: "50000 100000"},
+		{cpuMax: "0.001", want: "1000 100000"},
+		{cpuMax: "0.1%", want: "1000 100000"},
+		{cpuMax: "25000 50000", want: "25000 50000"},
+		{cpuMax: "max 100000", want: "max 100000"},
+		{cpuMax: "0", err: true},
+		{cpuMax: "-1", err: true},
+		{cpuMax: "half", err: true},
+		{cpuMax: "%", err: true},
+		{cpuMax: "25000 fast", err: true},
+	}
+	for _, test := range tests {
+		limits := Limits{CPUMax: test.cpuMax}
+		got, err := limits.cpuMax()
+		if test.err {
+			if err == nil {
+				t.Errorf("cpuMax() of %q = %q, want an error", test.cpuMax, got)
+			}
+			if limits.Validate() == nil {
+				t.Errorf("Validate() of cpu max %q returned no error", test.cpuMax)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("cpuMax() of %q returned %s", test.cpuMax, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("cpuMax() of %q = %q, want %q", test.cpuMax, got, test.want)
+		}
+	}
+}
@@ -3,11 +3,13 @@ package cli
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pmgo/lib/ecosystem"
 	"github.com/struCoder/pmgo/lib/master"
 	"github.com/struCoder/pmgo/lib/utils"
 )
@@ -17,10 +19,10 @@ type Cli struct {
 	remoteClient *master.RemoteClient
 }
 
-// InitCli initiates a remote client connecting to dsn.
+// InitCli initiates a remote client connecting to dsn, authenticated with auth on TCP.
 // Returns a Cli instance.
-func InitCli(dsn string, timeout time.Duration) *Cli {
-	client, err := master.StartRemoteClient(dsn, timeout)
+func InitCli(dsn string, timeout time.Duration, auth *master.ClientAuth) *Cli {
+	client, err := master.StartRemoteClient(dsn, timeout, auth)
 	if err != nil {
 		log.Fatalf("Failed to start remote client due to: %+v\n", err)
 	}
@@ -40,13 +42,76 @@ func (cli *Cli) Save() {
 
 // StartGoBin will try to start a go binary process.
 // Returns a fatal error in case there's any.
-func (cli *Cli) StartGoBin(sourcePath string, name string, keepAlive bool, args []string) {
-	err := cli.remoteClient.StartGoBin(sourcePath, name, keepAlive, args)
+func (cli *Cli) StartGoBin(goBin *master.GoBin) {
+	err := cli.remoteClient.StartGoBin(goBin)
+	if err == master.ErrProcessExists {
+		log.Warnf("Proc %s already exists, use pmgo restart to restart it.", goBin.Name)
+		return
+	}
 	if err != nil {
 		log.Fatalf("Failed to start go bin due to: %+v\n", err)
 	}
 }
 
+// StartEcosystem will start every app declared on the ecosystem file filename and
+// display whether each one of them succeeded or failed.
+func (cli *Cli) StartEcosystem(filename string) {
+	eco, err := ecosystem.Load(filename)
+	if err != nil {
+		log.Fatalf("Failed to load ecosystem file due to: %+v\n", err)
+	}
+
+	table := utils.GetTableWriter()
+	table.SetAutoWrapText(true)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"name", "source", "result"})
+	failed := 0
+	for _, app := range eco.Apps {
+		result := color.GreenString("started")
+		if err := cli.remoteClient.StartGoBin(app.GoBin()); err == master.ErrProcessExists {
+			result = color.YellowString(master.AlreadyExists)
+		} else if err != nil {
+			result = color.RedString(err.Error())
+			failed++
+		}
+		table.Append([]string{color.CyanString(app.Name), app.Source, result})
+	}
+	table.SetRowLine(true)
+	table.Render()
+	if failed > 0 {
+		log.Errorf("%d of %d apps failed to start", failed, len(eco.Apps))
+	}
+}
+
+// Resurrect will restore the procs saved by Save and display the result of every one of them.
+func (cli *Cli) Resurrect() {
+	response, err := cli.remoteClient.Resurrect()
+	if err != nil {
+		log.Fatalf("Failed to resurrect processes due to: %+v\n", err)
+	}
+
+	table := utils.GetTableWriter()
+	table.SetAutoWrapText(true)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"name", "result"})
+	failed := 0
+	for _, proc := range response.Procs {
+		result := color.GreenString(proc.Result)
+		if proc.Error != "" {
+			result = color.RedString(proc.Error)
+			failed++
+		} else if proc.Result == master.AlreadyExists || proc.Result == master.StoppedNotSaved {
+			result = color.YellowString(proc.Result)
+		}
+		table.Append([]string{color.CyanString(proc.Name), result})
+	}
+	table.SetRowLine(true)
+	table.Render()
+	if failed > 0 {
+		log.Errorf("%d of %d procs failed to be resurrected", failed, len(response.Procs))
+	}
+}
+
 // RestartProcess will try to restart a process with procName. Note that this process
 // must have been already started through StartGoBin.
 func (cli *Cli) RestartProcess(procName string) {
@@ -61,6 +126,80 @@ func (cli *Cli) RestartProcess(procName string) {
 	}
 }
 
+// ReloadProcess will start a new instance of process procName before stopping the current one.
+func (cli *Cli) ReloadProcess(procName string) {
+	isExist := cli.remoteClient.GetProcByName(procName)
+	if len(*isExist) == 0 {
+		log.Errorf("porcess %s not found", procName)
+		return
+	}
+	err := cli.remoteClient.ReloadProcess(procName)
+	if err != nil {
+		log.Fatalf("Failed to reload process due to: %+v\n", err)
+	}
+}
+
+// RebuildProcess will build process procName again from its saved source and restart it.
+// The running binary is kept when the build fails.
+func (cli *Cli) RebuildProcess(procName string) {
+	isExist := cli.remoteClient.GetProcByName(procName)
+	if len(*isExist) == 0 {
+		log.Errorf("porcess %s not found", procName)
+		return
+	}
+	err := cli.remoteClient.RebuildProcess(procName)
+	if err != nil {
+		log.Fatalf("Failed to rebuild process due to: %+v\n", err)
+	}
+}
+
+// History will display the builds kept of process procName, newest first.
+func (cli *Cli) History(procName string) {
+	response, err := cli.remoteClient.History(procName)
+	if err != nil {
+		log.Fatalf("Failed to get the builds of process due to: %+v\n", err)
+	}
+
+	table := utils.GetTableWriter()
+	table.SetAutoWrapText(true)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"version", "built at", "commit", "source hash", "build"})
+	for _, build := range response.Builds {
+		version := build.Version
+		if version == response.Active {
+			version = color.GreenString(version + " (active)")
+		}
+		table.Append([]string{version, build.BuiltAt.Format(time.RFC3339), shortCommit(build.Commit), shorten(build.SourceHash), build.Build})
+	}
+	table.SetRowLine(true)
+	table.Render()
+}
+
+// shortCommit will shorten a git commit for display, keeping its -dirty suffix.
+func shortCommit(commit string) string {
+	if strings.HasSuffix(commit, "-dirty") {
+		return shorten(strings.TrimSuffix(commit, "-dirty")) + "-dirty"
+	}
+	return shorten(commit)
+}
+
+func shorten(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// RollbackProcess will switch process procName back to the build version, or to the build before
+// the active one when version is empty, and restart it.
+func (cli *Cli) RollbackProcess(procName string, version string) {
+	rolledBack, err := cli.remoteClient.RollbackProcess(procName, version)
+	if err != nil {
+		log.Fatalf("Failed to rollback process due to: %+v\n", err)
+	}
+	log.Infof("proc: %s rolled back to build %s", procName, rolledBack)
+}
+
 // StartProcess will try to start a process with procName. Note that this process
 // must have been already started through StartGoBin.
 func (cli *Cli) StartProcess(procName string) {
@@ -83,6 +222,71 @@ func (cli *Cli) StopProcess(procName string) {
 	}
 }
 
+// ScaleProcess will add or remove instances of cluster procName until it has instances of them.
+func (cli *Cli) ScaleProcess(procName string, instances int) {
+	err := cli.remoteClient.ScaleProcess(procName, instances)
+	if err != nil {
+		log.Fatalf("Failed to scale process due to: %+v\n", err)
+	}
+}
+
+// FlushProcess will truncate the out and err files of process procName.
+func (cli *Cli) FlushProcess(procName string) {
+	isExist := cli.remoteClient.GetProcByName(procName)
+	if len(*isExist) == 0 {
+		log.Errorf("porcess %s not found", procName)
+		return
+	}
+	err := cli.remoteClient.FlushProcess(procName)
+	if err != nil {
+		log.Fatalf("Failed to flush process logs due to: %+v\n", err)
+	}
+	log.Infof("proc: %s logs flushed", procName)
+}
+
+// logsColors are used to tell apart the procs on interleaved logs.
+var logsColors = []func(format string, a ...interface{}) string{
+	color.CyanString, color.MagentaString, color.YellowString, color.BlueString, color.GreenString,
+}
+
+// Logs will display the last lines of the out and err files of procName, or of every
+// process when procName is "all", and keep following them if follow is set.
+func (cli *Cli) Logs(procName string, lines int, follow bool, errOnly bool) {
+	request := &master.LogsRequest{
+		Lines:   lines,
+		ErrOnly: errOnly,
+		Offsets: make(map[string]int64),
+	}
+	if procName != "all" {
+		request.Names = []string{procName}
+	}
+	names := make(map[string]func(format string, a ...interface{}) string)
+	for {
+		response, err := cli.remoteClient.Logs(request)
+		if err != nil {
+			log.Fatalf("Failed to get logs due to: %+v\n", err)
+		}
+		for _, line := range response.Lines {
+			nameColor, ok := names[line.Name]
+			if !ok {
+				nameColor = logsColors[len(names)%len(logsColors)]
+				names[line.Name] = nameColor
+			}
+			prefix := nameColor("%s |", line.Name)
+			if line.Err {
+				prefix = nameColor("%s", line.Name) + color.RedString(" err|")
+			}
+			fmt.Println(prefix, line.Text)
+		}
+		if !follow {
+			return
+		}
+		request.Offsets = response.Offsets
+		request.Inodes = response.Inodes
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
 // DeleteProcess will stop and delete all dependencies from process procName forever.
 func (cli *Cli) DeleteProcess(procName string) {
 	isExist := cli.remoteClient.GetProcByName(procName)
@@ -106,17 +310,31 @@ func (cli *Cli) Status() {
 	table := utils.GetTableWriter()
 	table.SetAlignment(tablewriter.ALIGN_CENTER)
 	table.SetHeader([]string{
-		"name", "pid", "status", "uptime", "restart", "CPU·%", "memory",
+		"name", "pid", "status", "health", "uptime", "restart", "CPU·%", "memory",
 	})
 
 	for id := range procResponse.Procs {
 		proc := procResponse.Procs[id]
 		status := color.GreenString(proc.Status.Status)
-		if proc.Status.Status != "running" {
+		switch proc.Status.Status {
+		case "running":
+		case "errored":
 			status = color.RedString(proc.Status.Status)
+		default:
+			status = color.YellowString(proc.Status.Status)
+		}
+		health := "-"
+		switch proc.Status.Health.Status {
+		case "":
+		case "healthy":
+			health = color.GreenString(proc.Status.Health.Status)
+		case "unhealthy":
+			health = color.RedString(proc.Status.Health.Status)
+		default:
+			health = color.YellowString(proc.Status.Health.Status)
 		}
 		table.Append([]string{
-			color.CyanString(proc.Name), fmt.Sprintf("%d", proc.Pid), status, proc.Status.Uptime,
+			color.CyanString(proc.Name), fmt.Sprintf("%d", proc.Pid), status, health, proc.Status.Uptime,
 			strconv.Itoa(proc.Status.Restarts), strconv.Itoa(int(proc.Status.Sys.CPU)),
 			utils.FormatMemory(int(proc.Status.Sys.Memory)),
 		})
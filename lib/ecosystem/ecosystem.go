@@ -0,0 +1,323 @@
+/*
+Ecosystem package describes a set of applications on a single file, so a whole host can be
+brought up with one command:
+
+- pmgo start apps.toml
+
+Both TOML and YAML files are supported. The file format is chosen by its extension.
+*/
+package ecosystem
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/struCoder/pmgo/lib/build"
+	"github.com/struCoder/pmgo/lib/cgroup"
+	"github.com/struCoder/pmgo/lib/health"
+	"github.com/struCoder/pmgo/lib/logrotate"
+	"github.com/struCoder/pmgo/lib/master"
+	"github.com/struCoder/pmgo/lib/process"
+	"github.com/struCoder/pmgo/lib/sourcewatch"
+	"github.com/struCoder/pmgo/lib/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// App is a single application entry of an ecosystem file.
+type App struct {
+	Name      string            `toml:"name" yaml:"name"`             // Name is the process name that will be given to the process.
+	Source    string            `toml:"source" yaml:"source"`         // Source is the go source directory, relative to the ecosystem file.
+	Args      []string          `toml:"args" yaml:"args"`             // Args are the extra args passed to the binary.
+	KeepAlive *bool             `toml:"keep_alive" yaml:"keep_alive"` // KeepAlive defaults to true when omitted.
+	Env       map[string]string `toml:"env" yaml:"env"`               // Env are extra environment variables given to the process.
+	EnvFiles  []string          `toml:"env_file" yaml:"env_file"`     // EnvFiles are .env files, relative to the ecosystem file.
+	CleanEnv  bool              `toml:"clean_env" yaml:"clean_env"`   // CleanEnv will not inherit the daemon environment.
+	Cwd       string            `toml:"cwd" yaml:"cwd"`               // Cwd is the process working directory, relative to the ecosystem file. Defaults to Source.
+
+	Runtime     string `toml:"runtime" yaml:"runtime"`         // Runtime is go, the default, exec to run the Source binary or interpreter to run the Source script.
+	Interpreter string `toml:"interpreter" yaml:"interpreter"` // Interpreter runs the Source script, such as python3. Defaults from its extension.
+	Copy        bool   `toml:"copy" yaml:"copy"`               // Copy copies the Source binary into the pmgo folder on the exec runtime.
+
+	Package   string            `toml:"package" yaml:"package"`     // Package is the package built, relative to Source (ex: ./cmd/server).
+	Tags      []string          `toml:"tags" yaml:"tags"`           // Tags are the build tags.
+	Ldflags   string            `toml:"ldflags" yaml:"ldflags"`     // Ldflags are the go build linker flags.
+	Gcflags   string            `toml:"gcflags" yaml:"gcflags"`     // Gcflags are the go build compiler flags.
+	Trimpath  bool              `toml:"trimpath" yaml:"trimpath"`   // Trimpath builds with -trimpath.
+	Race      bool              `toml:"race" yaml:"race"`           // Race builds with the race detector.
+	BuildEnv  map[string]string `toml:"build_env" yaml:"build_env"` // BuildEnv are environment variables given to go build (ex: CGO_ENABLED: "0").
+	Toolchain string            `toml:"toolchain" yaml:"toolchain"` // Toolchain is the go binary building Source (ex: go1.22.4).
+
+	KeepBuilds int `toml:"keep_builds" yaml:"keep_builds"` // KeepBuilds is the number of builds kept for pmgo rollback.
+
+	LogMaxSize  string        `toml:"log_max_size" yaml:"log_max_size"` // LogMaxSize rotates the logs past this size (ex: 10M).
+	LogInterval time.Duration `toml:"log_interval" yaml:"log_interval"` // LogInterval rotates the logs after this long (ex: 24h).
+	LogKeep     int           `toml:"log_keep" yaml:"log_keep"`         // LogKeep is the number of rotated log files kept.
+	LogCompress bool          `toml:"log_compress" yaml:"log_compress"` // LogCompress gzips the rotated log files.
+
+	RestartDelay    time.Duration `toml:"restart_delay" yaml:"restart_delay"`         // RestartDelay is the delay before restarting a dead process.
+	MaxRestartDelay time.Duration `toml:"max_restart_delay" yaml:"max_restart_delay"` // MaxRestartDelay is the maximum delay between restarts.
+	MinUptime       time.Duration `toml:"min_uptime" yaml:"min_uptime"`               // MinUptime is the uptime after which a run is stable.
+	RestartJitter   *float64      `toml:"restart_jitter" yaml:"restart_jitter"`       // RestartJitter is the fraction of the delay randomly added or removed, 0 for none.
+	MaxRestarts     *int          `toml:"max_restarts" yaml:"max_restarts"`           // MaxRestarts are the restarts allowed within RestartWindow, -1 for unlimited.
+	RestartWindow   time.Duration `toml:"restart_window" yaml:"restart_window"`       // RestartWindow is the period MaxRestarts is counted on.
+	StopSignal      string        `toml:"stop_signal" yaml:"stop_signal"`             // StopSignal asks the process to stop. Defaults to SIGTERM.
+	KillTimeout     time.Duration `toml:"kill_timeout" yaml:"kill_timeout"`           // KillTimeout is the time given to stop before being killed.
+	Listen          []string      `toml:"listen" yaml:"listen"`                       // Listen are the sockets pmgo listens on for the process.
+	Instances       interface{}   `toml:"instances" yaml:"instances"`                 // Instances starts the app on cluster mode, a number or "max".
+
+	MaxMemory        string `toml:"max_memory" yaml:"max_memory"`                 // MaxMemory restarts the app when its memory stays above this size (ex: 512M).
+	MaxMemorySamples int    `toml:"max_memory_samples" yaml:"max_memory_samples"` // MaxMemorySamples are the samples in a row above MaxMemory before a restart.
+
+	CronRestart string `toml:"cron_restart" yaml:"cron_restart"` // CronRestart is a cron expression the app is restarted on (ex: "0 4 * * *").
+
+	Watch         bool          `toml:"watch" yaml:"watch"`                   // Watch rebuilds and restarts the app when its source changes.
+	WatchIgnore   []string      `toml:"watch_ignore" yaml:"watch_ignore"`     // WatchIgnore are globs of files and directories not watched.
+	WatchDebounce time.Duration `toml:"watch_debounce" yaml:"watch_debounce"` // WatchDebounce is the time the source must stay unchanged before a rebuild.
+
+	User   string   `toml:"user" yaml:"user"`     // User is the user, name or uid, the app runs as. Requires a root daemon.
+	Group  string   `toml:"group" yaml:"group"`   // Group is the group, name or gid, the app runs as.
+	Groups []string `toml:"groups" yaml:"groups"` // Groups are the supplementary groups of the app.
+
+	CPUMax    string `toml:"cpu_max" yaml:"cpu_max"`       // CPUMax are the CPUs the app may use (ex: 0.5 or 150%).
+	MemoryMax string `toml:"memory_max" yaml:"memory_max"` // MemoryMax is the memory the app is OOM killed above (ex: 512M).
+	PidsMax   int    `toml:"pids_max" yaml:"pids_max"`     // PidsMax is the maximum number of processes and threads of the app.
+	IOWeight  int    `toml:"io_weight" yaml:"io_weight"`   // IOWeight is the proportional IO weight of the app, from 1 to 10000.
+
+	HealthHTTP     string        `toml:"health_http" yaml:"health_http"`         // HealthHTTP is a URL probed with a GET expecting a 2xx status.
+	HealthTCP      string        `toml:"health_tcp" yaml:"health_tcp"`           // HealthTCP is a host:port probed with a TCP connect.
+	HealthExec     string        `toml:"health_exec" yaml:"health_exec"`         // HealthExec is a shell command probed expecting a 0 exit code.
+	HealthInterval time.Duration `toml:"health_interval" yaml:"health_interval"` // HealthInterval is the time between health probes.
+	HealthTimeout  time.Duration `toml:"health_timeout" yaml:"health_timeout"`   // HealthTimeout is the time a health probe may take.
+	HealthFailures int           `toml:"health_failures" yaml:"health_failures"` // HealthFailures are the failed probes in a row before a restart.
+
+	logRotate *logrotate.Config
+	instances int
+	maxMemory int64
+	memoryMax int64
+}
+
+// Ecosystem is the struct an ecosystem file will decode to.
+type Ecosystem struct {
+	Apps []*App `toml:"apps" yaml:"apps"`
+}
+
+// IsEcosystemFile will check whether filename looks like an ecosystem file.
+func IsEcosystemFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".toml", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// Load will read and validate the ecosystem file filename. Relative paths inside the file are
+// resolved against the directory the file lives in.
+// Returns a tuple with the ecosystem and an error in case there's any.
+func Load(filename string) (*Ecosystem, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	ecosystem := &Ecosystem{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".toml":
+		_, err = toml.Decode(string(content), ecosystem)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, ecosystem)
+	default:
+		err = fmt.Errorf("unsupported ecosystem file %s", filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ecosystem.Apps) == 0 {
+		return nil, errors.New("ecosystem file does not declare any app")
+	}
+
+	baseDir := filepath.Dir(filename)
+	names := make(map[string]bool)
+	for id, app := range ecosystem.Apps {
+		if app.Name == "" {
+			return nil, fmt.Errorf("app #%d has no name", id+1)
+		}
+		if names[app.Name] {
+			return nil, fmt.Errorf("app %s is declared more than once", app.Name)
+		}
+		names[app.Name] = true
+		if app.Source == "" {
+			return nil, fmt.Errorf("app %s has no source", app.Name)
+		}
+		app.Source = resolvePath(baseDir, app.Source)
+		if app.Cwd != "" {
+			app.Cwd = resolvePath(baseDir, app.Cwd)
+		}
+		for i := range app.EnvFiles {
+			app.EnvFiles[i] = resolvePath(baseDir, app.EnvFiles[i])
+		}
+		if err := app.parseLogRotate(); err != nil {
+			return nil, fmt.Errorf("app %s: %s", app.Name, err)
+		}
+		if err := app.parseInstances(); err != nil {
+			return nil, fmt.Errorf("app %s: %s", app.Name, err)
+		}
+		if app.MaxMemory != "" {
+			if app.maxMemory, err = utils.ParseSize(app.MaxMemory); err != nil {
+				return nil, fmt.Errorf("app %s: %s", app.Name, err)
+			}
+		}
+		if app.MemoryMax != "" {
+			if app.memoryMax, err = utils.ParseSize(app.MemoryMax); err != nil {
+				return nil, fmt.Errorf("app %s: %s", app.Name, err)
+			}
+		}
+	}
+	return ecosystem, nil
+}
+
+// GoBin will convert app into the arguments StartGoBin expects.
+func (app *App) GoBin() *master.GoBin {
+	keepAlive := true
+	if app.KeepAlive != nil {
+		keepAlive = *app.KeepAlive
+	}
+	return &master.GoBin{
+		SourcePath: app.Source,
+		Name:       app.Name,
+		KeepAlive:  keepAlive,
+		Args:       app.Args,
+		Env:        app.Env,
+		EnvFiles:   app.EnvFiles,
+		CleanEnv:   app.CleanEnv,
+		Cwd:        app.Cwd,
+		LogRotate:  app.logRotate,
+
+		Runtime:     app.Runtime,
+		Interpreter: app.Interpreter,
+		Copy:        app.Copy,
+		Build: build.Spec{
+			Package:   app.Package,
+			Tags:      app.Tags,
+			Ldflags:   app.Ldflags,
+			Gcflags:   app.Gcflags,
+			Trimpath:  app.Trimpath,
+			Race:      app.Race,
+			Env:       app.BuildEnv,
+			Toolchain: app.Toolchain,
+		},
+		KeepBuilds: app.KeepBuilds,
+
+		RestartPolicy: app.restartPolicy(),
+		StopSignal:    app.StopSignal,
+		KillTimeout:   app.KillTimeout,
+		Sockets:       app.Listen,
+		Instances:     app.instances,
+
+		MaxMemory:        app.maxMemory,
+		MaxMemorySamples: app.MaxMemorySamples,
+		CronRestart:      app.CronRestart,
+		SourceWatch: sourcewatch.Config{
+			Enabled:  app.Watch,
+			Ignore:   app.WatchIgnore,
+			Debounce: app.WatchDebounce,
+		},
+
+		User:   app.User,
+		Group:  app.Group,
+		Groups: app.Groups,
+		Cgroup: cgroup.Limits{
+			CPUMax:    app.CPUMax,
+			MemoryMax: app.memoryMax,
+			PidsMax:   app.PidsMax,
+			IOWeight:  app.IOWeight,
+		},
+
+		HealthCheck: health.Check{
+			HTTP:     app.HealthHTTP,
+			TCP:      app.HealthTCP,
+			Exec:     app.HealthExec,
+			Interval: app.HealthInterval,
+			Timeout:  app.HealthTimeout,
+			Failures: app.HealthFailures,
+		},
+	}
+}
+
+// parseInstances converts Instances, where max is one instance per CPU of the daemon host,
+// to the GoBin Instances field.
+func (app *App) parseInstances() error {
+	n := 0
+	switch instances := app.Instances.(type) {
+	case nil:
+		return nil
+	case int:
+		n = instances
+	case int64:
+		n = int(instances)
+	case string:
+		if instances == "max" {
+			app.instances = -1
+			return nil
+		}
+		n, _ = strconv.Atoi(instances)
+	}
+	if n < 1 {
+		return errors.New("instances must be a positive number or max")
+	}
+	app.instances = n
+	return nil
+}
+
+// restartPolicy returns the restart policy of app, or nil when not configured
+// so the daemon default applies.
+func (app *App) restartPolicy() *process.RestartPolicy {
+	policy := &process.RestartPolicy{
+		Delay:       app.RestartDelay,
+		MaxDelay:    app.MaxRestartDelay,
+		Jitter:      app.RestartJitter,
+		MinUptime:   app.MinUptime,
+		MaxRestarts: app.MaxRestarts,
+		Window:      app.RestartWindow,
+	}
+	if *policy == (process.RestartPolicy{}) {
+		return nil
+	}
+	return policy
+}
+
+// parseLogRotate builds the log rotation of app, leaving it nil when not configured
+// so the daemon default applies.
+func (app *App) parseLogRotate() error {
+	if app.LogMaxSize == "" && app.LogInterval == 0 && app.LogKeep == 0 && !app.LogCompress {
+		return nil
+	}
+	app.logRotate = &logrotate.Config{
+		Interval: app.LogInterval,
+		Keep:     app.LogKeep,
+		Compress: app.LogCompress,
+	}
+	if app.LogMaxSize != "" {
+		size, err := utils.ParseSize(app.LogMaxSize)
+		if err != nil {
+			return err
+		}
+		app.logRotate.MaxSize = size
+	}
+	return nil
+}
+
+func resolvePath(baseDir string, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(baseDir, p)
+}
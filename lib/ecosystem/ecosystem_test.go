@@ -0,0 +1,179 @@
+package ecosystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		check    func(t *testing.T, dir string, ecosystem *Ecosystem)
+		err      bool
+	}{
+		{
+			name:     "toml",
+			filename: "apps.toml",
+			content: `
+[[apps]]
+name = "api"
+source = "./api"
+cwd = "run"
+env_file = [".env", "/etc/api.env"]
+log_max_size = "10M"
+log_interval = "24h"
+max_memory = "512M"
+instances = 2
+
+[[apps]]
+name = "worker"
+source = "/srv/worker"
+keep_alive = false
+instances = "max"
+`,
+			check: func(t *testing.T, dir string, ecosystem *Ecosystem) {
+				if len(ecosystem.Apps) != 2 {
+					t.Fatalf("apps = %d, want 2", len(ecosystem.Apps))
+				}
+				api, worker := ecosystem.Apps[0], ecosystem.Apps[1]
+				if want := filepath.Join(dir, "api"); api.Source != want {
+					t.Errorf("source = %s, want %s", api.Source, want)
+				}
+				if want := filepath.Join(dir, "run"); api.Cwd != want {
+					t.Errorf("cwd = %s, want %s", api.Cwd, want)
+				}
+				if want := []string{filepath.Join(dir, ".env"), "/etc/api.env"}; !reflect.DeepEqual(api.EnvFiles, want) {
+					t.Errorf("env files = %v, want %v", api.EnvFiles, want)
+				}
+				if api.logRotate == nil || api.logRotate.MaxSize != 10*1024*1024 || api.logRotate.Interval != 24*time.Hour {
+					t.Errorf("log rotate = %+v, want 10M every 24h", api.logRotate)
+				}
+				if api.maxMemory != 512*1024*1024 {
+					t.Errorf("max memory = %d, want 512M", api.maxMemory)
+				}
+				if api.instances != 2 || worker.instances != -1 {
+					t.Errorf("instances = %d and %d, want 2 and -1", api.instances, worker.instances)
+				}
+				if worker.Source != "/srv/worker" {
+					t.Errorf("source = %s, want /srv/worker", worker.Source)
+				}
+				if api.GoBin().KeepAlive != true || worker.GoBin().KeepAlive != false {
+					t.Error("keep alive should default to true and be disabled by keep_alive = false")
+				}
+			},
+		},
+		{
+			name:     "yaml",
+			filename: "apps.yml",
+			content: `
+apps:
+  - name: api
+    source: api
+    args: ["--port", "8080"]
+    env:
+      MODE: production
+    restart_delay: 2s
+    max_restarts: 0
+`,
+			check: func(t *testing.T, dir string, ecosystem *Ecosystem) {
+				if len(ecosystem.Apps) != 1 {
+					t.Fatalf("apps = %d, want 1", len(ecosystem.Apps))
+				}
+				api := ecosystem.Apps[0]
+				if want := filepath.Join(dir, "api"); api.Source != want {
+					t.Errorf("source = %s, want %s", api.Source, want)
+				}
+				if want := []string{"--port", "8080"}; !reflect.DeepEqual(api.Args, want) {
+					t.Errorf("args = %v, want %v", api.Args, want)
+				}
+				if api.Env["MODE"] != "production" {
+					t.Errorf("env = %v, want MODE=production", api.Env)
+				}
+				if api.RestartDelay != 2*time.Second {
+					t.Errorf("restart delay = %s, want 2s", api.RestartDelay)
+				}
+				if api.MaxRestarts == nil || *api.MaxRestarts != 0 {
+					t.Errorf("max restarts = %v, want 0", api.MaxRestarts)
+				}
+			},
+		},
+		{
+			name:     "unsupported extension",
+			filename: "apps.json",
+			content:  `{"apps": [{"name": "api", "source": "api"}]}`,
+			err:      true,
+		},
+		{
+			name:     "invalid toml",
+			filename: "apps.toml",
+			content:  "[[apps]\nname = ",
+			err:      true,
+		},
+		{
+			name:     "no apps",
+			filename: "apps.toml",
+			content:  "",
+			err:      true,
+		},
+		{
+			name:     "no name",
+			filename: "apps.toml",
+			content:  "[[apps]]\nsource = \"api\"\n",
+			err:      true,
+		},
+		{
+			name:     "no source",
+			filename: "apps.toml",
+			content:  "[[apps]]\nname = \"api\"\n",
+			err:      true,
+		},
+		{
+			name:     "duplicated name",
+			filename: "apps.yaml",
+			content:  "apps:\n  - {name: api, source: a}\n  - {name: api, source: b}\n",
+			err:      true,
+		},
+		{
+			name:     "invalid instances",
+			filename: "apps.toml",
+			content:  "[[apps]]\nname = \"api\"\nsource = \"api\"\ninstances = 0\n",
+			err:      true,
+		},
+		{
+			name:     "invalid max memory",
+			filename: "apps.toml",
+			content:  "[[apps]]\nname = \"api\"\nsource = \"api\"\nmax_memory = \"lots\"\n",
+			err:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ecosystem")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, test.filename)
+			if err := ioutil.WriteFile(filename, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ecosystem, err := Load(filename)
+			if test.err {
+				if err == nil {
+					t.Fatal("Load returned no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load returned %s", err)
+			}
+			test.check(t, dir, ecosystem)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Health package probes whether a running process is actually working, so a process that is
+alive but deadlocked can be restarted. A probe is either:
+
+- an HTTP GET expecting a 2xx status
+- a TCP connect
+- a shell command expecting a 0 exit code
+*/
+package health
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// Starting is the health of a process that was not probed since it started.
+	Starting = "starting"
+	// Healthy is the health of a process whose last probe succeeded.
+	Healthy = "healthy"
+	// Unhealthy is the health of a process whose last probe failed.
+	Unhealthy = "unhealthy"
+)
+
+// DefaultCheck holds the values used for the fields a Check leaves unset.
+var DefaultCheck = Check{
+	Interval: 10 * time.Second,
+	Timeout:  5 * time.Second,
+	Failures: 3,
+}
+
+// Check is a health probe of a process. At most one of HTTP, TCP and Exec is set.
+type Check struct {
+	HTTP     string        // HTTP is a URL expected to answer a GET with a 2xx status.
+	TCP      string        // TCP is a host:port expected to accept connections.
+	Exec     string        // Exec is a shell command expected to exit with 0, run on the process working directory and environment.
+	Interval time.Duration // Interval is the time between probes. The first probe runs one interval after the process started.
+	Timeout  time.Duration // Timeout is how long a probe may take before it fails.
+	Failures int           // Failures is the number of failed probes in a row after which the process is restarted.
+}
+
+// Result is the outcome of the latest probes of a process.
+type Result struct {
+	Status    string // Status is starting, healthy or unhealthy.
+	Failures  int    // Failures is the number of failed probes in a row.
+	Output    string // Output is the error of the last failed probe.
+	CheckedAt int64  // CheckedAt is the unix time of the last probe.
+}
+
+// Enabled will return true if check probes anything.
+func (check Check) Enabled() bool {
+	return check.HTTP != "" || check.TCP != "" || check.Exec != ""
+}
+
+// Validate will return an error if check sets more than one probe.
+func (check Check) Validate() error {
+	probes := 0
+	for _, probe := range []string{check.HTTP, check.TCP, check.Exec} {
+		if probe != "" {
+			probes++
+		}
+	}
+	if probes > 1 {
+		return errors.New("only one of the http, tcp and exec health checks can be set")
+	}
+	return nil
+}
+
+// WithDefaults will return the check with every unset field taken from DefaultCheck.
+func (check Check) WithDefaults() Check {
+	if check.Interval <= 0 {
+		check.Interval = DefaultCheck.Interval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultCheck.Timeout
+	}
+	if check.Failures <= 0 {
+		check.Failures = DefaultCheck.Failures
+	}
+	return check
+}
+
+// String will describe check, such as "http GET http://:8080/health every 10s".
+func (check Check) String() string {
+	probe := ""
+	switch {
+	case check.HTTP != "":
+		probe = "http GET " + check.HTTP
+	case check.TCP != "":
+		probe = "tcp connect " + check.TCP
+	case check.Exec != "":
+		probe = "exec " + check.Exec
+	default:
+		return ""
+	}
+	check = check.WithDefaults()
+	return fmt.Sprintf("%s every %s (timeout %s), restart after %d failures", probe, check.Interval, check.Timeout, check.Failures)
+}
+
+// Probe will run check once. Exec commands run on dir with env.
+// Returns an error in case the probe failed.
+func (check Check) Probe(dir string, env []string) error {
+	timeout := check.WithDefaults().Timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	switch {
+	case check.HTTP != "":
+		return probeHTTP(ctx, check.HTTP)
+	case check.TCP != "":
+		conn, err := net.DialTimeout("tcp", check.TCP, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case check.Exec != "":
+		return probeExec(ctx, check.Exec, dir, env)
+	}
+	return nil
+}
+
+// probeExec will run command with sh on its own process group, so the commands it started
+// are killed with it when ctx is done instead of keeping the probe waiting for its output.
+func probeExec(ctx context.Context, command string, dir string, env []string) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %s", err, strings.TrimSpace(output.String()))
+		}
+		return nil
+	case <-ctx.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return fmt.Errorf("timed out: %s", strings.TrimSpace(output.String()))
+	}
+}
+
+func probeHTTP(ctx context.Context, url string) error {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package health
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+	dir, err := ioutil.TempDir("", "health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "ready"), nil, 0644)
+
+	tests := []struct {
+		name  string
+		check Check
+		err   string // err is part of the expected error, empty when the probe succeeds.
+	}{
+		{name: "http ok", check: Check{HTTP: server.URL + "/health"}},
+		{name: "http bad status", check: Check{HTTP: server.URL + "/down"}, err: "503"},
+		{name: "tcp ok", check: Check{TCP: listener.Addr().String()}},
+		{name: "tcp refused", check: Check{TCP: closed.Addr().String()}, err: "refused"},
+		{name: "exec ok", check: Check{Exec: `test -f ready && test "$APP_ENV" = prod`}},
+		{name: "exec failed", check: Check{Exec: "echo not ready; exit 1"}, err: "not ready"},
+		{name: "exec timeout", check: Check{Exec: "sleep 5", Timeout: 100 * time.Millisecond}, err: "timed out"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			begin := time.Now()
+			err := test.check.Probe(dir, []string{"APP_ENV=prod"})
+			if took := time.Since(begin); took > 2*time.Second {
+				t.Fatalf("probe took %s, past its timeout", took)
+			}
+			if test.err == "" && err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("got error %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+}
@@ -1 +1,135 @@
+/*
+Install package generates the systemd unit that starts the pmgo daemon at boot and resurrects
+the list of procs saved with pmgo save:
+
+- pmgo startup
+- pmgo startup --user
+
+The unit is written, but systemd is not reloaded: the commands enabling it are returned so the
+user can run them.
+*/
 package install
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// ServiceName is the name of the systemd service pmgo runs as.
+const ServiceName = "pmgo"
+
+// Unit describes the systemd unit of the pmgo daemon.
+type Unit struct {
+	Bin        string // Bin is the pmgo binary path.
+	Dsn        string // Dsn is the TCP address resurrect reaches the daemon on, the TCPAddr of ConfigFile. Empty uses the unix socket.
+	ConfigFile string // ConfigFile is the config file of the daemon, next to its unix socket.
+	Home       string // Home is the HOME of the daemon, where the .pmgo folder lives.
+	User       string // User runs the daemon on a system unit, and is kept logged in for a user unit.
+	Path       string // Path is the PATH of the daemon, so procs can be rebuilt with the go tool.
+	UserUnit   bool   // UserUnit is a systemd user unit instead of a system one.
+
+	// Env are the PMGO_* settings resurrect authenticates to the daemon TCP address with, such as
+	// PMGO_TOKEN or PMGO_TLS_CA. The unit file is only readable by its owner when it is not empty.
+	Env map[string]string
+}
+
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=pmgo process manager
+After=network.target
+
+[Service]
+Type=forking
+{{- if and (not .UserUnit) .User}}
+User={{.User}}
+{{- end}}
+Environment=HOME={{.Home}}
+Environment=PATH={{.Path}}
+{{- range $key, $value := .Env}}
+Environment={{printf "%q" (printf "%s=%s" $key $value)}}
+{{- end}}
+PIDFile={{.PidFile}}
+ExecStart={{.Bin}} serve --config-file={{.ConfigFile}}
+ExecStartPost=-{{.Bin}}{{if .Dsn}} --dns={{.Dsn}}{{end}} resurrect --config-file={{.ConfigFile}}
+KillMode=mixed
+Delegate=yes
+Restart=on-failure
+
+[Install]
+WantedBy={{.WantedBy}}
+`))
+
+// PidFile will return the pid file of the daemon, inside Home.
+func (unit *Unit) PidFile() string {
+	return filepath.Join(unit.Home, ".pmgo", "main.pid")
+}
+
+// WantedBy will return the target the unit is enabled on.
+func (unit *Unit) WantedBy() string {
+	if unit.UserUnit {
+		return "default.target"
+	}
+	return "multi-user.target"
+}
+
+// File will return where the unit is written to.
+func (unit *Unit) File() string {
+	if unit.UserUnit {
+		return filepath.Join(unit.Home, ".config", "systemd", "user", ServiceName+".service")
+	}
+	return filepath.Join("/etc", "systemd", "system", ServiceName+".service")
+}
+
+// Render will return the content of the unit file.
+// Returns a tuple with the content and an error in case there's any.
+func (unit *Unit) Render() (string, error) {
+	var buf bytes.Buffer
+	if err := unitTemplate.Execute(&buf, unit); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Install will write the unit file.
+// Returns an error in case there's any.
+func (unit *Unit) Install() error {
+	content, err := unit.Render()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(unit.File()), 0755); err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if len(unit.Env) > 0 {
+		// the token would be readable by every user otherwise
+		mode = 0600
+	}
+	if err := ioutil.WriteFile(unit.File(), []byte(content), mode); err != nil {
+		if os.IsPermission(err) && !unit.UserUnit {
+			return fmt.Errorf("%s, run pmgo startup as root or install a user unit with --user", err)
+		}
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(unit.File(), mode)
+}
+
+// Commands will return the systemctl commands enabling the unit.
+func (unit *Unit) Commands() []string {
+	systemctl := "systemctl"
+	if unit.UserUnit {
+		systemctl = "systemctl --user"
+	}
+	commands := []string{
+		systemctl + " daemon-reload",
+		systemctl + " enable " + ServiceName,
+	}
+	if unit.UserUnit {
+		commands = append(commands, "loginctl enable-linger "+unit.User)
+	}
+	return commands
+}
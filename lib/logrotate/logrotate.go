@@ -0,0 +1,396 @@
+/*
+Logrotate package provides a log file writer that rotates itself by size and age, keeps a
+limited number of rotated files around and optionally gzips them.
+
+The daemon owns the pipes connected to each process stdout and stderr and copies them through
+a Writer, so rotation happens without restarting the process. The last rotation time is kept as
+the modification time of <filename>.rotated, so restarts don't reset the rotation interval.
+*/
+package logrotate
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pmgo/lib/utils"
+)
+
+// timeFormat is appended to the rotated file names.
+const timeFormat = "20060102-150405"
+
+// stampSuffix is appended to the name of the file whose modification time is the last rotation.
+const stampSuffix = ".rotated"
+
+const (
+	maxMarks     = 8192 // maxMarks is the number of writes whose sequence is remembered.
+	maxRotations = 8    // maxRotations is the number of rotated file names remembered.
+)
+
+// sequence numbers the writes of every Writer, so lines of different files can be merged in the
+// order the daemon read them.
+var sequence uint64
+
+// mark is the sequence of the write that started at offset.
+type mark struct {
+	offset   int64
+	sequence uint64
+}
+
+// rotation is the name a file was rotated to.
+type rotation struct {
+	inode   uint64
+	rotated string
+}
+
+// Config describes when a log file should be rotated and how many rotated files are kept.
+type Config struct {
+	MaxSize  int64         // MaxSize is the size in bytes after which the file is rotated. 0 disables it.
+	Interval time.Duration // Interval is the age after which the file is rotated. 0 disables it.
+	Keep     int           // Keep is the number of rotated files retained. 0 keeps all of them.
+	Compress bool          // Compress will gzip the rotated files.
+}
+
+// Enabled will return true if the config rotates files at all.
+func (config Config) Enabled() bool {
+	return config.MaxSize > 0 || config.Interval > 0
+}
+
+// Writer is an io.Writer on a log file that rotates according to its Config.
+type Writer struct {
+	sync.Mutex
+	filename  string
+	config    Config
+	file      *os.File
+	size      int64
+	rotatedAt time.Time // rotatedAt is when the file was last rotated, or first created.
+	uid       int       // uid owns the log file, -1 keeps the daemon user.
+	gid       int       // gid owns the log file, -1 keeps the daemon group.
+	done      chan struct{}
+	marks     []mark
+	rotations []rotation
+}
+
+// Open will open filename in append mode for writing with the rotation rules on config.
+// Returns a tuple with the writer and an error in case there's any.
+func Open(filename string, config Config) (*Writer, error) {
+	writer := &Writer{
+		filename: filename,
+		config:   config,
+		uid:      -1,
+		gid:      -1,
+		done:     make(chan struct{}),
+	}
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	writer.rotatedAt = writer.loadStamp()
+	if config.Interval > 0 {
+		go writer.tick()
+	}
+	return writer, nil
+}
+
+// Write will write p to the log file, rotating it first if it is too big or too old.
+// Returns the number of bytes written and an error in case there's any.
+func (writer *Writer) Write(p []byte) (int, error) {
+	writer.Lock()
+	defer writer.Unlock()
+	if writer.shouldRotate(int64(len(p))) {
+		if err := writer.rotate(); err != nil {
+			log.Errorf("Failed to rotate %s due to %s", writer.filename, err)
+		}
+	}
+	if len(writer.marks) >= maxMarks {
+		writer.marks = append(writer.marks[:0], writer.marks[maxMarks/2:]...)
+	}
+	writer.marks = append(writer.marks, mark{offset: writer.size, sequence: atomic.AddUint64(&sequence, 1)})
+	n, err := writer.file.Write(p)
+	writer.size += int64(n)
+	return n, err
+}
+
+// Rotate will rotate the log file right away.
+// Returns an error in case there's any.
+func (writer *Writer) Rotate() error {
+	writer.Lock()
+	defer writer.Unlock()
+	return writer.rotate()
+}
+
+// Truncate will empty the current log file, the rotated ones are left untouched.
+// Returns an error in case there's any.
+func (writer *Writer) Truncate() error {
+	writer.Lock()
+	defer writer.Unlock()
+	if err := writer.file.Truncate(0); err != nil {
+		return err
+	}
+	writer.size = 0
+	writer.marks = nil
+	return nil
+}
+
+// Sequence will return the order, among the writes of every Writer, of the write of the byte at
+// offset of the current file, or 0 when it is not remembered.
+func (writer *Writer) Sequence(offset int64) uint64 {
+	writer.Lock()
+	defer writer.Unlock()
+	i := sort.Search(len(writer.marks), func(i int) bool { return writer.marks[i].offset > offset })
+	if i == 0 {
+		return 0
+	}
+	return writer.marks[i-1].sequence
+}
+
+// Rotated will return the name the file with inode was rotated to, suffixed with .gz once it is
+// compressed, and false when it is not remembered.
+func (writer *Writer) Rotated(inode uint64) (string, bool) {
+	writer.Lock()
+	defer writer.Unlock()
+	for _, rotation := range writer.rotations {
+		if rotation.inode == inode {
+			if !fileExists(rotation.rotated) && fileExists(rotation.rotated+".gz") {
+				return rotation.rotated + ".gz", true
+			}
+			return rotation.rotated, true
+		}
+	}
+	return "", false
+}
+
+// Inode will return the inode of the file described by info, so a reader can tell it was rotated.
+func Inode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
+
+// Close will close the log file.
+// Returns an error in case there's any.
+func (writer *Writer) Close() error {
+	writer.Lock()
+	defer writer.Unlock()
+	select {
+	case <-writer.done:
+	default:
+		close(writer.done)
+	}
+	return writer.file.Close()
+}
+
+// Copy will copy everything read from r into the writer until r is closed, closing both afterwards.
+func (writer *Writer) Copy(r io.ReadCloser) {
+	if _, err := io.Copy(writer, r); err != nil {
+		log.Warnf("Stopped copying logs to %s due to %s", writer.filename, err)
+	}
+	r.Close()
+	writer.Close()
+}
+
+// RotatedFiles will return the rotated files of filename, oldest first.
+func RotatedFiles(filename string) []string {
+	matches, _ := filepath.Glob(filename + ".*")
+	rotated := []string{}
+	for _, match := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(match, filename+"."), ".gz")
+		if _, err := time.Parse(timeFormat, suffix); err == nil {
+			rotated = append(rotated, match)
+		}
+	}
+	sort.Strings(rotated)
+	return rotated
+}
+
+// Remove will remove the rotated files of filename and its last rotation time.
+func Remove(filename string) {
+	for _, rotated := range RotatedFiles(filename) {
+		os.Remove(rotated)
+	}
+	os.Remove(filename + stampSuffix)
+}
+
+// Chown will change the owner of the log file, and of the files created on later rotations.
+// Returns an error in case there's any.
+func (writer *Writer) Chown(uid int, gid int) error {
+	writer.Lock()
+	defer writer.Unlock()
+	writer.uid = uid
+	writer.gid = gid
+	return writer.file.Chown(uid, gid)
+}
+
+func (writer *Writer) open() error {
+	file, err := utils.GetFile(writer.filename)
+	if err != nil {
+		return err
+	}
+	if writer.uid >= 0 || writer.gid >= 0 {
+		file.Chown(writer.uid, writer.gid)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	writer.file = file
+	writer.size = info.Size()
+	return nil
+}
+
+// tick will rotate the file once its interval is over, even when nothing is written to it,
+// until the writer is closed.
+func (writer *Writer) tick() {
+	period := writer.config.Interval / 10
+	if period < time.Second {
+		period = time.Second
+	} else if period > time.Minute {
+		period = time.Minute
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-writer.done:
+			return
+		case <-ticker.C:
+		}
+		writer.Lock()
+		if time.Since(writer.rotatedAt) >= writer.config.Interval {
+			if writer.size == 0 {
+				// nothing to rotate, the interval starts over
+				writer.saveStamp()
+			} else if err := writer.rotate(); err != nil {
+				log.Errorf("Failed to rotate %s due to %s", writer.filename, err)
+			}
+		}
+		writer.Unlock()
+	}
+}
+
+func (writer *Writer) shouldRotate(incoming int64) bool {
+	if writer.config.MaxSize > 0 && writer.size > 0 && writer.size+incoming > writer.config.MaxSize {
+		return true
+	}
+	return writer.config.Interval > 0 && time.Since(writer.rotatedAt) >= writer.config.Interval
+}
+
+// NOT thread safe method. Lock should be acquired before calling it.
+func (writer *Writer) rotate() error {
+	rotated := writer.filename + "." + time.Now().Format(timeFormat)
+	// do not overwrite a file rotated during the same second
+	for next := time.Now().Add(time.Second); fileExists(rotated) || fileExists(rotated+".gz"); next = next.Add(time.Second) {
+		rotated = writer.filename + "." + next.Format(timeFormat)
+	}
+	// the open file is renamed, so it keeps being written to when anything below fails
+	if err := os.Rename(writer.filename, rotated); err != nil {
+		return err
+	}
+	previous := writer.file
+	if err := writer.open(); err != nil {
+		if renameErr := os.Rename(rotated, writer.filename); renameErr != nil {
+			log.Errorf("Failed to restore %s due to %s, logs are written to %s", writer.filename, renameErr, rotated)
+		}
+		return err
+	}
+	if info, err := previous.Stat(); err == nil {
+		if len(writer.rotations) >= maxRotations {
+			writer.rotations = writer.rotations[1:]
+		}
+		writer.rotations = append(writer.rotations, rotation{inode: Inode(info), rotated: rotated})
+	}
+	previous.Close()
+	writer.marks = nil
+	writer.saveStamp()
+	go writer.cleanup(rotated)
+	return nil
+}
+
+// loadStamp will return when the file was last rotated, starting its interval now when it never was.
+func (writer *Writer) loadStamp() time.Time {
+	if writer.config.Interval <= 0 {
+		return time.Now()
+	}
+	if info, err := os.Stat(writer.filename + stampSuffix); err == nil {
+		return info.ModTime()
+	}
+	return writer.saveStamp()
+}
+
+// saveStamp will record now as the last rotation.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (writer *Writer) saveStamp() time.Time {
+	writer.rotatedAt = time.Now()
+	if writer.config.Interval <= 0 {
+		return writer.rotatedAt
+	}
+	stamp := writer.filename + stampSuffix
+	err := os.Chtimes(stamp, writer.rotatedAt, writer.rotatedAt)
+	if os.IsNotExist(err) {
+		err = ioutil.WriteFile(stamp, nil, 0660)
+	}
+	if err != nil {
+		log.Warnf("Failed to save the rotation time of %s due to %s", writer.filename, err)
+	}
+	return writer.rotatedAt
+}
+
+// cleanup will compress the freshly rotated file and remove the files beyond the retention count.
+func (writer *Writer) cleanup(rotated string) {
+	if writer.config.Compress {
+		if err := compress(rotated); err != nil {
+			log.Errorf("Failed to compress %s due to %s", rotated, err)
+		}
+	}
+	if writer.config.Keep <= 0 {
+		return
+	}
+	files := RotatedFiles(writer.filename)
+	for len(files) > writer.config.Keep {
+		if err := os.Remove(files[0]); err != nil {
+			log.Errorf("Failed to remove %s due to %s", files[0], err)
+		}
+		files = files[1:]
+	}
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+func compress(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(filename+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(filename)
+}
@@ -0,0 +1,209 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		writes   []string
+		current  string
+		rotated  []string // rotated are the contents of the rotated files, oldest first.
+		compress bool
+	}{
+		{
+			name:    "disabled",
+			writes:  []string{"abc\n", "def\n"},
+			current: "abc\ndef\n",
+		},
+		{
+			name:    "max size",
+			config:  Config{MaxSize: 4},
+			writes:  []string{"abc\n", "def\n", "gh\n"},
+			current: "gh\n",
+			rotated: []string{"abc\n", "def\n"},
+		},
+		{
+			name:    "write bigger than max size",
+			config:  Config{MaxSize: 4},
+			writes:  []string{"abcdefgh\n", "ij\n"},
+			current: "ij\n",
+			rotated: []string{"abcdefgh\n"},
+		},
+		{
+			name:    "keep",
+			config:  Config{MaxSize: 4, Keep: 1},
+			writes:  []string{"abc\n", "def\n", "gh\n"},
+			current: "gh\n",
+			rotated: []string{"def\n"},
+		},
+		{
+			name:     "compress",
+			config:   Config{MaxSize: 4, Compress: true},
+			writes:   []string{"abc\n", "def\n"},
+			current:  "def\n",
+			rotated:  []string{"abc\n"},
+			compress: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(tempDir(t), "out.log")
+			writer, err := Open(filename, test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer writer.Close()
+			for _, write := range test.writes {
+				if _, err := writer.Write([]byte(write)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := readFile(t, filename); got != test.current {
+				t.Errorf("current file = %q, want %q", got, test.current)
+			}
+			// rotated files are compressed and removed in the background
+			rotated := waitRotated(filename, len(test.rotated), test.compress)
+			if len(rotated) != len(test.rotated) {
+				t.Fatalf("rotated files = %v, want %d of them", rotated, len(test.rotated))
+			}
+			for i, file := range rotated {
+				if compressed := strings.HasSuffix(file, ".gz"); compressed != test.compress {
+					t.Errorf("rotated file %s compressed = %v, want %v", file, compressed, test.compress)
+				}
+				if got := readFile(t, file); got != test.rotated[i] {
+					t.Errorf("rotated file %s = %q, want %q", file, got, test.rotated[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriterSequence(t *testing.T) {
+	filename := filepath.Join(tempDir(t), "out.log")
+	writer, err := Open(filename, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	writer.Write([]byte("abc\n"))
+	writer.Write([]byte("de\n"))
+
+	tests := []struct {
+		offset int64
+		write  int // write is the index of the write of the byte at offset, -1 for none.
+	}{
+		{offset: 0, write: 0},
+		{offset: 3, write: 0},
+		{offset: 4, write: 1},
+		{offset: 6, write: 1},
+	}
+	first := writer.Sequence(0)
+	if first == 0 {
+		t.Fatal("Sequence(0) = 0, want the sequence of the first write")
+	}
+	for _, test := range tests {
+		if got, want := writer.Sequence(test.offset), first+uint64(test.write); got != want {
+			t.Errorf("Sequence(%d) = %d, want %d", test.offset, got, want)
+		}
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := writer.Sequence(0); got != 0 {
+		t.Errorf("Sequence(0) after rotation = %d, want 0", got)
+	}
+	rotated, ok := writer.Rotated(Inode(info))
+	if !ok || readFile(t, rotated) != "abc\nde\n" {
+		t.Errorf("Rotated(%d) = %s, %v, want the file holding the previous writes", Inode(info), rotated, ok)
+	}
+	if _, ok := writer.Rotated(Inode(info) + 1); ok {
+		t.Errorf("Rotated(%d) = true for an unknown inode", Inode(info)+1)
+	}
+}
+
+func TestWriterTruncate(t *testing.T) {
+	filename := filepath.Join(tempDir(t), "out.log")
+	writer, err := Open(filename, Config{MaxSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	writer.Write([]byte("abcdef\n"))
+	if err := writer.Truncate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := writer.Sequence(0); got != 0 {
+		t.Errorf("Sequence(0) after truncate = %d, want 0", got)
+	}
+	// the size is reset too, so this write fits without a rotation
+	writer.Write([]byte("ghijkl\n"))
+	if got := readFile(t, filename); got != "ghijkl\n" {
+		t.Errorf("file = %q, want %q", got, "ghijkl\n")
+	}
+	if rotated := RotatedFiles(filename); len(rotated) != 0 {
+		t.Errorf("rotated files = %v, want none", rotated)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// waitRotated will wait for count rotated files of filename, compressed or not, and return them.
+func waitRotated(filename string, count int, compressed bool) []string {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rotated := RotatedFiles(filename)
+		done := len(rotated) == count
+		for _, file := range rotated {
+			done = done && strings.HasSuffix(file, ".gz") == compressed
+		}
+		if done || time.Now().After(deadline) {
+			return rotated
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readFile(t *testing.T, filename string) string {
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if !strings.HasSuffix(filename, ".gz") {
+		content, err := ioutil.ReadAll(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
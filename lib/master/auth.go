@@ -0,0 +1,283 @@
+package master
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/rpc"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// authTimeout is how long a TCP client has to send its token.
+	authTimeout = 10 * time.Second
+	// maxAuthLine is the maximum length of the token line.
+	maxAuthLine = 4096
+	// authPrefix starts the token line.
+	authPrefix = "AUTH "
+)
+
+// errUnauthorized is all rejected clients are told.
+var errUnauthorized = errors.New("unauthorized")
+
+// ClientAuth is how a RemoteClient authenticates to a daemon listening on TCP.
+type ClientAuth struct {
+	Token    string // Token is sent to the daemon before any call.
+	TLS      bool   // TLS will connect using TLS. It is implied by the other TLS fields.
+	CAFile   string // CAFile is the CA certificate verifying the daemon. Defaults to the system ones.
+	CertFile string // CertFile is the client certificate, for daemons requiring mutual TLS.
+	KeyFile  string // KeyFile is the client certificate key.
+}
+
+// useTLS will return true if the client connects using TLS.
+func (auth *ClientAuth) useTLS() bool {
+	return auth.TLS || auth.CAFile != "" || auth.CertFile != ""
+}
+
+// tlsConfig will return the TLS config of the client connecting to address.
+// Returns a tuple with the config and an error in case there's any.
+func (auth *ClientAuth) tlsConfig(address string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if host, _, err := net.SplitHostPort(address); err == nil && host != "" {
+		config.ServerName = host
+	}
+	if auth.CAFile != "" {
+		pool, err := readCertPool(auth.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if auth.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(auth.CertFile, auth.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// authenticate will send the token line on conn and wait for the daemon to accept it.
+// Returns an error in case there's any.
+func (auth *ClientAuth) authenticate(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if _, err := fmt.Fprintf(conn, "%s%s\n", authPrefix, auth.Token); err != nil {
+		return err
+	}
+	line, err := readAuthLine(conn)
+	if err != nil {
+		return err
+	}
+	if line != "OK" {
+		return errors.New(strings.TrimPrefix(line, "ERROR "))
+	}
+	return nil
+}
+
+// checkAuthConfig will return an error if the TCP listeners would receive tokens in clear text.
+func (master *Master) checkAuthConfig() error {
+	if master.TokenFile != "" && master.TLSCertFile == "" {
+		return errors.New("TokenFile is set without TLSCertFile, tokens would be sent in clear text")
+	}
+	return nil
+}
+
+// checkExposed will return an error if addr is not a loopback address while the daemon has neither
+// tokens nor client certificates, since anyone reaching it could then run any command.
+func (master *Master) checkExposed(addr string) error {
+	if !isLoopback(addr) && master.TokenFile == "" && master.TLSClientCAFile == "" {
+		return fmt.Errorf("refusing to listen on %s without authentication, set TokenFile or TLSClientCAFile or listen on a loopback address", addr)
+	}
+	return nil
+}
+
+// serverTLSConfig will return the TLS config of the daemon TCP listeners, nil when TLS is disabled.
+// Clients must present a certificate signed by TLSClientCAFile when it is set.
+// Returns a tuple with the config and an error in case there's any.
+func (master *Master) serverTLSConfig() (*tls.Config, error) {
+	if master.TLSCertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(master.TLSCertFile, master.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if master.TLSClientCAFile != "" {
+		pool, err := readCertPool(master.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// tokens will read the tokens accepted by the daemon from TokenFile, one per line.
+// The file is read on every check so tokens can be changed without restarting the daemon.
+// Returns a tuple with the tokens, nil when auth is disabled, and an error in case there's any.
+func (master *Master) tokens() ([]string, error) {
+	if master.TokenFile == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(master.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	tokens := []string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			tokens = append(tokens, line)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no token found on %s", master.TokenFile)
+	}
+	return tokens, nil
+}
+
+// checkToken will return nil if token is accepted by the daemon.
+func (master *Master) checkToken(token string) error {
+	tokens, err := master.tokens()
+	if err != nil {
+		return err
+	}
+	if tokens == nil {
+		return nil
+	}
+	for _, valid := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+			return nil
+		}
+	}
+	return errors.New("invalid token")
+}
+
+// acceptTCP will serve the RPC connections of l once they have sent an accepted token.
+func (master *Master) acceptTCP(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Errorf("Failed to accept TCP connection due to %s", err)
+			return
+		}
+		go master.serveTCP(conn)
+	}
+}
+
+// serveTCP will serve the RPC calls of conn once it has sent an accepted token. Without a TokenFile,
+// plain net/rpc clients are served too, and the token line of pmgo clients is just acknowledged.
+func (master *Master) serveTCP(conn net.Conn) {
+	tokens, err := master.tokens()
+	disabled := err == nil && tokens == nil
+	buffered := &bufferedConn{Conn: conn, reader: bufio.NewReader(conn)}
+	if !disabled {
+		conn.SetDeadline(time.Now().Add(authTimeout))
+	}
+	prefix, err := buffered.reader.Peek(len(authPrefix))
+	if err == nil && string(prefix) != authPrefix {
+		if disabled {
+			rpc.ServeConn(buffered)
+			return
+		}
+		err = errors.New("missing token")
+	}
+	if err == nil {
+		conn.SetDeadline(time.Now().Add(authTimeout))
+		var line string
+		if line, err = readAuthLine(buffered); err == nil {
+			err = master.checkToken(strings.TrimPrefix(line, authPrefix))
+		}
+	}
+	if err != nil {
+		// the details, such as the token file path, are only for the daemon log
+		log.Warnf("Rejected connection from %s: %s", conn.RemoteAddr(), err)
+		fmt.Fprintf(conn, "ERROR %s\n", errUnauthorized)
+		conn.Close()
+		return
+	}
+	if _, err := fmt.Fprint(conn, "OK\n"); err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	rpc.ServeConn(buffered)
+}
+
+// bufferedConn is a connection whose first bytes were peeked at.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (conn *bufferedConn) Read(p []byte) (int, error) {
+	return conn.reader.Read(p)
+}
+
+// readAuthLine will read a line from conn one byte at a time, so nothing after it is consumed.
+// Returns a tuple with the line and an error in case there's any.
+func readAuthLine(conn net.Conn) (string, error) {
+	line := []byte{}
+	b := make([]byte, 1)
+	for len(line) < maxAuthLine {
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return strings.TrimSuffix(string(line), "\r"), nil
+		}
+		line = append(line, b[0])
+	}
+	return "", errors.New("token line too long")
+}
+
+func readCertPool(filename string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found on %s", filename)
+	}
+	return pool, nil
+}
+
+// isAuthorized will check the bearer token of a REST API request.
+// Returns an error in case the request is rejected.
+func (master *Master) isAuthorized(authorization string) error {
+	tokens, err := master.tokens()
+	if err != nil || tokens == nil {
+		return err
+	}
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return errors.New("missing bearer token")
+	}
+	return master.checkToken(strings.TrimPrefix(authorization, "Bearer "))
+}
+
+// authorize will reject the HTTP requests without an accepted bearer token, when the daemon has a TokenFile.
+func (master *Master) authorize(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := master.isAuthorized(r.Header.Get("Authorization")); err != nil {
+			log.Warnf("Rejected HTTP request from %s: %s", r.RemoteAddr, err)
+			writeError(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
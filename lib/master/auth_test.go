@@ -0,0 +1,165 @@
+package master
+
+import (
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var registerOnce sync.Once
+
+// listenTCP will serve the RPC calls of master on a loopback TCP address, closed with the test.
+// Returns the address.
+func listenTCP(t *testing.T, master *Master) string {
+	registerOnce.Do(func() {
+		rpc.Register(&RemoteMaster{master: &Master{}})
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go master.acceptTCP(l)
+	return l.Addr().String()
+}
+
+func writeTokens(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	filename := filepath.Join(dir, "tokens")
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestTCPTokenAuth(t *testing.T) {
+	tokenFile := writeTokens(t, "# deploy\ngood\n\n  other  \n")
+	tests := []struct {
+		name      string
+		tokenFile string
+		token     string
+		plain     bool // plain uses a net/rpc client, sending no token line.
+		ok        bool
+	}{
+		{name: "accepted token", tokenFile: tokenFile, token: "good", ok: true},
+		{name: "second token trimmed", tokenFile: tokenFile, token: "other", ok: true},
+		{name: "rejected token", tokenFile: tokenFile, token: "bad"},
+		{name: "comment is no token", tokenFile: tokenFile, token: "# deploy"},
+		{name: "missing token", tokenFile: tokenFile, token: ""},
+		{name: "plain client with tokens", tokenFile: tokenFile, plain: true},
+		{name: "unreadable token file", tokenFile: tokenFile + ".missing", token: "good"},
+		{name: "no token file", token: "", ok: true},
+		{name: "no token file with a token", token: "anything", ok: true},
+		{name: "plain client without tokens", plain: true, ok: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr := listenTCP(t, &Master{TokenFile: test.tokenFile})
+			var err error
+			if test.plain {
+				var client *rpc.Client
+				if client, err = rpc.Dial("tcp", addr); err != nil {
+					t.Fatal(err)
+				}
+				defer client.Close()
+				done := make(chan error, 1)
+				go func() {
+					var response ProcResponse
+					done <- client.Call("RemoteMaster.MonitStatus", "", &response)
+				}()
+				select {
+				case err = <-done:
+				case <-time.After(authTimeout + 5*time.Second):
+					t.Fatal("the call never returned")
+				}
+			} else {
+				var client *RemoteClient
+				client, err = StartRemoteClient(addr, 5*time.Second, &ClientAuth{Token: test.token})
+				if err == nil {
+					defer client.conn.Close()
+					_, err = client.MonitStatus()
+				}
+			}
+			if test.ok && err != nil {
+				t.Errorf("call failed with %s", err)
+			}
+			if !test.ok {
+				if err == nil {
+					t.Error("call succeeded, want it rejected")
+				} else if !test.plain && err.Error() != errUnauthorized.Error() {
+					t.Errorf("rejected with %q, want only %q", err, errUnauthorized)
+				}
+			}
+		})
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	tokenFile := writeTokens(t, "good\n")
+	tests := []struct {
+		tokenFile     string
+		authorization string
+		ok            bool
+	}{
+		{tokenFile: tokenFile, authorization: "Bearer good", ok: true},
+		{tokenFile: tokenFile, authorization: "Bearer bad"},
+		{tokenFile: tokenFile, authorization: "good"},
+		{tokenFile: tokenFile, authorization: ""},
+		{authorization: "", ok: true},
+	}
+	for _, test := range tests {
+		master := &Master{TokenFile: test.tokenFile}
+		if err := master.isAuthorized(test.authorization); (err == nil) != test.ok {
+			t.Errorf("isAuthorized(%q) with token file %q = %v, want accepted %v", test.authorization, test.tokenFile, err, test.ok)
+		}
+	}
+}
+
+func TestCheckExposed(t *testing.T) {
+	tests := []struct {
+		addr   string
+		master *Master
+		ok     bool
+	}{
+		{addr: "127.0.0.1:9876", master: &Master{}, ok: true},
+		{addr: "localhost:9876", master: &Master{}, ok: true},
+		{addr: "[::1]:9876", master: &Master{}, ok: true},
+		{addr: ":9876", master: &Master{}},
+		{addr: "0.0.0.0:9876", master: &Master{}},
+		{addr: "10.0.0.1:9876", master: &Master{}},
+		{addr: "0.0.0.0:9876", master: &Master{TokenFile: "/etc/pmgo/tokens"}, ok: true},
+		{addr: "0.0.0.0:9876", master: &Master{TLSClientCAFile: "/etc/pmgo/ca.pem"}, ok: true},
+		{addr: "0.0.0.0:9876", master: &Master{TLSCertFile: "/etc/pmgo/server.pem"}},
+	}
+	for _, test := range tests {
+		if err := test.master.checkExposed(test.addr); (err == nil) != test.ok {
+			t.Errorf("checkExposed(%q) = %v, want accepted %v", test.addr, err, test.ok)
+		}
+	}
+}
+
+func TestCheckAuthConfig(t *testing.T) {
+	tests := []struct {
+		master *Master
+		ok     bool
+	}{
+		{master: &Master{}, ok: true},
+		{master: &Master{TokenFile: "/etc/pmgo/tokens"}},
+		{master: &Master{TokenFile: "/etc/pmgo/tokens", TLSCertFile: "/etc/pmgo/server.pem"}, ok: true},
+		{master: &Master{TLSCertFile: "/etc/pmgo/server.pem"}, ok: true},
+	}
+	for _, test := range tests {
+		if err := test.master.checkAuthConfig(); (err == nil) != test.ok {
+			t.Errorf("checkAuthConfig() of token file %q and certificate %q = %v, want accepted %v", test.master.TokenFile, test.master.TLSCertFile, err, test.ok)
+		}
+	}
+}
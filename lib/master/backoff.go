@@ -0,0 +1,91 @@
+package master
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pmgo/lib/process"
+)
+
+// crashState keeps track of the recent crashes of a proc, so it can be restarted with backoff
+// and errored when it keeps crashing.
+type crashState struct {
+	crashes  []time.Time // crashes are the times the proc died within the policy window.
+	failures int         // failures is the number of unstable runs in a row.
+	timer    *time.Timer // timer is the pending restart, if any.
+}
+
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) getCrashState(proc process.ProcContainer) *crashState {
+	state, ok := master.crashes[proc.Identifier()]
+	if !ok {
+		state = &crashState{}
+		master.crashes[proc.Identifier()] = state
+	}
+	return state
+}
+
+// scheduleRestart will record that proc died and restart it once its backoff delay is over.
+// When proc restarted more than its policy allows, it is set to errored instead and will only
+// run again after an explicit restart.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) scheduleRestart(proc process.ProcContainer) {
+	policy := proc.GetRestartPolicy().WithDefaults()
+	state := master.getCrashState(proc)
+	now := time.Now()
+	uptime := now.Sub(time.Unix(proc.GetStatus().StartTime, 0))
+	if uptime >= policy.MinUptime {
+		state.failures = 0
+		state.crashes = nil
+	}
+	state.failures++
+	crashes := []time.Time{}
+	for _, crash := range state.crashes {
+		if now.Sub(crash) < policy.Window {
+			crashes = append(crashes, crash)
+		}
+	}
+	state.crashes = append(crashes, now)
+
+	proc.NotifyStopped()
+	if allowed := policy.RestartsAllowed(); allowed >= 0 && len(state.crashes) > allowed {
+		log.Errorf("Proc %s restarted %d times within %s. It is now errored and needs an explicit restart.",
+			proc.Identifier(), len(state.crashes)-1, policy.Window)
+		proc.SetStatus("errored")
+		master.saveProcsWrapper()
+		return
+	}
+
+	reason := fmt.Sprintf("exited with code %d", proc.GetStatus().ExitCode)
+	delay := policy.Backoff(state.failures)
+	log.Infof("Restarting proc %s in %s.", proc.Identifier(), delay)
+	proc.SetStatus("waiting restart")
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		master.Lock()
+		defer master.Unlock()
+		// the restart was cancelled meanwhile
+		if state.timer != timer {
+			return
+		}
+		state.timer = nil
+		if err := master.restart(proc, reason); err != nil {
+			log.Warnf("Could not restart process %s due to %s.", proc.Identifier(), err)
+		}
+	})
+	state.timer = timer
+}
+
+// resetCrashState will cancel any pending restart of proc and forget its crashes. It is called
+// whenever proc is explicitly started, stopped or deleted.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) resetCrashState(proc process.ProcContainer) {
+	if state, ok := master.crashes[proc.Identifier()]; ok {
+		if state.timer != nil {
+			state.timer.Stop()
+			state.timer = nil
+		}
+		delete(master.crashes, proc.Identifier())
+	}
+}
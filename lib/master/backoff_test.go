@@ -0,0 +1,55 @@
+package master
+
+import (
+	"testing"
+	"time"
+
+	"github.com/struCoder/pmgo/lib/process"
+)
+
+func TestCrashLoopErrors(t *testing.T) {
+	master := newTestMaster(t)
+	go master.WatchProcs()
+	proc := newTestProc(t, master, "app", "exit 3")
+	proc.KeepAlive = true
+	proc.RestartPolicy = process.RestartPolicy{
+		Delay:       50 * time.Millisecond,
+		MaxDelay:    100 * time.Millisecond,
+		Jitter:      process.Float(0),
+		MaxRestarts: process.Int(2),
+	}
+	status := func() string {
+		master.Lock()
+		defer master.Unlock()
+		return proc.GetStatus().Status
+	}
+	master.Lock()
+	err := master.start(proc)
+	master.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, 5*time.Second, "a pending restart", func() bool { return status() == "waiting restart" })
+	waitFor(t, 5*time.Second, "the proc to be errored", func() bool { return status() == "errored" })
+	master.Lock()
+	restarts, code := proc.GetStatus().Restarts, proc.GetStatus().ExitCode
+	pending := master.crashes[proc.Identifier()].timer != nil
+	master.Unlock()
+	if restarts != 2 {
+		t.Fatalf("got %d restarts before errored, want 2", restarts)
+	}
+	if code != 3 {
+		t.Fatalf("got exit code %d, want 3", code)
+	}
+	if pending {
+		t.Fatal("an errored proc must not have a pending restart")
+	}
+
+	// errored procs only run again after an explicit restart, which forgets the crashes
+	if err := master.RestartProcess("app"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "a restart after the explicit one", func() bool { return status() == "waiting restart" })
+	master.Stop()
+}
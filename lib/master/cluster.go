@@ -0,0 +1,130 @@
+package master
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pmgo/lib/preparable"
+	"github.com/struCoder/pmgo/lib/process"
+)
+
+// lookup will return the proc named name, or every instance of the cluster named name
+// sorted by instance id.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) lookup(name string) []process.ProcContainer {
+	if proc, ok := master.Procs[name]; ok {
+		return []process.ProcContainer{proc}
+	}
+	return master.instances(name)
+}
+
+// instances will return every instance of cluster sorted by instance id.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) instances(cluster string) []process.ProcContainer {
+	instances := []process.ProcContainer{}
+	for _, proc := range master.Procs {
+		if proc.GetCluster() == cluster {
+			instances = append(instances, proc)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].GetInstanceID() < instances[j].GetInstanceID()
+	})
+	return instances
+}
+
+// runInstances will start every instance of the cluster of procPreparable, built once,
+// and add them to the watch list. When an instance fails to start, the ones already started
+// are stopped and removed, so the cluster is not left half started.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) runInstances(procPreparable preparable.ProcPreparable) error {
+	started := []process.ProcContainer{}
+	for instance := 0; instance < procPreparable.GetInstances(); instance++ {
+		proc, err := procPreparable.StartInstance(instance)
+		if err != nil {
+			master.removeInstances(started)
+			return fmt.Errorf("Failed to start instance %d due to %s", instance, err)
+		}
+		master.Procs[proc.Identifier()] = proc
+		master.Watcher.AddProcWatcher(proc)
+		proc.SetStatus("running")
+		started = append(started, proc)
+	}
+	return master.saveProcsWrapper()
+}
+
+// removeInstances will stop and remove the instances of a cluster that failed to start, along
+// with their files. The cluster folder is kept, it holds the build.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) removeInstances(instances []process.ProcContainer) {
+	for _, proc := range instances {
+		log.Infof("Removing instance %s of a cluster that failed to start", proc.Identifier())
+		if err := master.stop(proc); err != nil {
+			log.Warnf("Failed to stop instance %s due to %s", proc.Identifier(), err)
+		}
+		delete(master.Procs, proc.Identifier())
+		if err := master.delete(proc); err != nil {
+			log.Warnf("Failed to delete instance %s due to %s", proc.Identifier(), err)
+		}
+	}
+	master.saveProcsWrapper()
+}
+
+// ScaleProcess will start or delete instances of the cluster named name until it has instances of them.
+// Instances are added after the highest instance id and deleted from it.
+func (master *Master) ScaleProcess(name string, instances int) error {
+	master.Lock()
+	defer master.Unlock()
+	if instances < 1 {
+		return errors.New("A cluster needs at least one instance.")
+	}
+	current := master.instances(name)
+	if len(current) == 0 {
+		if _, ok := master.Procs[name]; ok {
+			return fmt.Errorf("Proc %s was not started on cluster mode.", name)
+		}
+		return ErrUnknownProcess
+	}
+	for len(current) < instances {
+		next := current[len(current)-1].GetInstanceID() + 1
+		proc := current[0].Clone(next)
+		log.Infof("Scaling up proc %s with instance %s", name, proc.Identifier())
+		master.Procs[proc.Identifier()] = proc
+		current = append(current, proc)
+		if err := master.start(proc); err != nil {
+			return err
+		}
+	}
+	for len(current) > instances {
+		proc := current[len(current)-1]
+		log.Infof("Scaling down proc %s by deleting instance %s", name, proc.Identifier())
+		if err := master.deleteProc(proc); err != nil {
+			return err
+		}
+		current = current[:len(current)-1]
+	}
+	return master.saveProcsWrapper()
+}
+
+// deleteProc will stop proc and delete it with all its files. The folder shared by a
+// cluster is deleted along with its last instance.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) deleteProc(proc process.ProcContainer) error {
+	master.resetCrashState(proc)
+	err := master.stop(proc)
+	if err != nil {
+		return err
+	}
+	delete(master.Procs, proc.Identifier())
+	err = master.delete(proc)
+	if err != nil {
+		return err
+	}
+	if proc.GetCluster() != "" && len(master.instances(proc.GetCluster())) == 0 {
+		return os.RemoveAll(proc.GetPath())
+	}
+	return nil
+}
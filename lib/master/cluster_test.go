@@ -0,0 +1,56 @@
+package master
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/struCoder/pmgo/lib/preparable"
+	"github.com/struCoder/pmgo/lib/process"
+)
+
+// failingPreparable fails to start the instance failAt of its cluster.
+type failingPreparable struct {
+	preparable.ProcPreparable
+	failAt int
+}
+
+func (failing *failingPreparable) StartInstance(instance int) (process.ProcContainer, error) {
+	if instance == failing.failAt {
+		return nil, errors.New("no more room")
+	}
+	return failing.ProcPreparable.StartInstance(instance)
+}
+
+func TestRunInstancesRemovesStartedOnFailure(t *testing.T) {
+	master := newTestMaster(t)
+	procPreparable := &failingPreparable{
+		ProcPreparable: &preparable.Preparable{
+			Name:      "api",
+			Cmd:       "/bin/sh",
+			Args:      []string{"-c", "exec sleep 30"},
+			SysFolder: master.SysFolder,
+			Language:  process.RuntimeExec,
+			Instances: 3,
+		},
+		failAt: 2,
+	}
+	if err := os.MkdirAll(master.SysFolder+"api", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	master.Lock()
+	err := master.runInstances(procPreparable)
+	master.Unlock()
+	if err == nil {
+		t.Fatal("the cluster started with a failing instance")
+	}
+	if len(master.Procs) != 0 {
+		t.Fatalf("got %d procs registered after the failure, want none", len(master.Procs))
+	}
+	for _, name := range []string{"api-0.out", "api-1.out"} {
+		if _, err := os.Stat(master.SysFolder + "api/" + name); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", name, err)
+		}
+	}
+}
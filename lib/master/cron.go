@@ -0,0 +1,90 @@
+package master
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pmgo/lib/process"
+)
+
+// cronState is the next scheduled restart of a proc.
+type cronState struct {
+	spec     string        // spec is the cron expression schedule was parsed from.
+	schedule cron.Schedule // schedule computes the restart times.
+	next     time.Time     // next is the next restart time.
+}
+
+// CronRestarts will loop, until pmgo stops, restarting the procs that have a CronRestart expression when it is due.
+// Procs that are not running when their restart is due are left alone.
+func (master *Master) CronRestarts() {
+	for {
+		master.Lock()
+		if master.stopping {
+			master.Unlock()
+			return
+		}
+		due := master.dueCronRestarts(time.Now())
+		master.Unlock()
+		for name, spec := range due {
+			log.Infof("Cron restart of proc %s.", name)
+			if err := master.restartProcess(name, fmt.Sprintf("cron restart %q", spec)); err != nil {
+				log.Warnf("Could not restart process %s due to %s.", name, err)
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// dueCronRestarts will schedule the next restart of the procs whose restart is due at now.
+// Returns the running ones among them, with their cron expression.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) dueCronRestarts(now time.Time) map[string]string {
+	for name := range master.cron {
+		if _, ok := master.Procs[name]; !ok {
+			delete(master.cron, name)
+		}
+	}
+	due := map[string]string{}
+	for _, proc := range master.ListProcs() {
+		spec := proc.GetCronRestart()
+		if spec == "" {
+			delete(master.cron, proc.Identifier())
+			continue
+		}
+		state, ok := master.cron[proc.Identifier()]
+		if !ok || state.spec != spec {
+			schedule, err := cron.ParseStandard(spec)
+			if err != nil {
+				log.Warnf("Invalid cron restart %q of proc %s: %s", spec, proc.Identifier(), err)
+				continue
+			}
+			state = &cronState{spec: spec, schedule: schedule, next: schedule.Next(now)}
+			master.cron[proc.Identifier()] = state
+		}
+		if now.Before(state.next) {
+			continue
+		}
+		state.next = state.schedule.Next(now)
+		if proc.IsAlive() {
+			due[proc.Identifier()] = spec
+		}
+	}
+	return due
+}
+
+// formatCronRestart will describe the cron expression of proc and its next restart time.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) formatCronRestart(proc process.ProcContainer) string {
+	spec := proc.GetCronRestart()
+	state, ok := master.cron[proc.Identifier()]
+	if !ok || state.spec != spec {
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			return fmt.Sprintf("%s (invalid: %s)", spec, err)
+		}
+		return fmt.Sprintf("%s (next at %s)", spec, schedule.Next(time.Now()).Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s (next at %s)", spec, state.next.Format(time.RFC3339))
+}
@@ -0,0 +1,63 @@
+package master
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDueCronRestarts(t *testing.T) {
+	master := newTestMaster(t)
+	running := newTestProc(t, master, "running", "exec sleep 30")
+	running.CronRestart = "*/5 * * * *"
+	if err := master.start(running); err != nil {
+		t.Fatal(err)
+	}
+	defer master.stop(running)
+	newTestProc(t, master, "stopped", "exit 0").CronRestart = "*/5 * * * *"
+	newTestProc(t, master, "invalid", "exit 0").CronRestart = "bogus"
+	newTestProc(t, master, "none", "exit 0")
+
+	at := func(hour int, min int) time.Time {
+		return time.Date(2026, 1, 1, hour, min, 0, 0, time.Local)
+	}
+	tests := []struct {
+		now  time.Time
+		due  map[string]string
+		next time.Time // next is the restart scheduled for running after now.
+	}{
+		{now: at(10, 1), due: map[string]string{}, next: at(10, 5)},
+		{now: at(10, 4), due: map[string]string{}, next: at(10, 5)},
+		{now: at(10, 5), due: map[string]string{"running": "*/5 * * * *"}, next: at(10, 10)},
+		{now: at(10, 6), due: map[string]string{}, next: at(10, 10)},
+		// a missed restart happens once
+		{now: at(11, 2), due: map[string]string{"running": "*/5 * * * *"}, next: at(11, 5)},
+	}
+	for _, test := range tests {
+		due := master.dueCronRestarts(test.now)
+		if !reflect.DeepEqual(due, test.due) {
+			t.Errorf("at %s: got due %v, want %v", test.now.Format("15:04"), due, test.due)
+		}
+		if next := master.cron["running"].next; !next.Equal(test.next) {
+			t.Errorf("at %s: got next restart at %s, want %s", test.now.Format("15:04"), next.Format("15:04"), test.next.Format("15:04"))
+		}
+	}
+	if _, ok := master.cron["stopped"]; !ok {
+		t.Error("the restarts of a stopped proc must still be scheduled")
+	}
+	if _, ok := master.cron["invalid"]; ok {
+		t.Error("an invalid cron expression was scheduled")
+	}
+
+	// changing the expression reschedules the proc, removing it forgets it
+	running.CronRestart = "0 * * * *"
+	master.dueCronRestarts(at(11, 3))
+	if next := master.cron["running"].next; !next.Equal(at(12, 0)) {
+		t.Errorf("got next restart at %s after changing the expression, want 12:00", next.Format("15:04"))
+	}
+	delete(master.Procs, "stopped")
+	master.dueCronRestarts(at(11, 4))
+	if _, ok := master.cron["stopped"]; ok {
+		t.Error("the restarts of a deleted proc are still scheduled")
+	}
+}
@@ -0,0 +1,113 @@
+package master
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pmgo/lib/health"
+	"github.com/struCoder/pmgo/lib/process"
+)
+
+// healthState keeps track of the health probes of a proc run.
+type healthState struct {
+	pid     int       // pid is the proc run being probed.
+	next    time.Time // next is when the next probe is due.
+	probing bool      // probing is true while a probe is running.
+}
+
+// HealthChecks will loop, until pmgo stops, probing the running procs that have a health check, and restart
+// the ones failing too many probes in a row.
+func (master *Master) HealthChecks() {
+	for {
+		master.Lock()
+		if master.stopping {
+			master.Unlock()
+			return
+		}
+		now := time.Now()
+		for name := range master.health {
+			if _, ok := master.Procs[name]; !ok {
+				delete(master.health, name)
+			}
+		}
+		for _, proc := range master.ListProcs() {
+			check := proc.GetHealthCheck().WithDefaults()
+			if !check.Enabled() {
+				continue
+			}
+			if !proc.IsAlive() {
+				delete(master.health, proc.Identifier())
+				proc.SetHealth(health.Result{})
+				continue
+			}
+			state, ok := master.health[proc.Identifier()]
+			if !ok || state.pid != proc.GetPid() {
+				// a new run is given one interval to start before it is probed
+				state = &healthState{pid: proc.GetPid(), next: now.Add(check.Interval)}
+				master.health[proc.Identifier()] = state
+				proc.SetHealth(health.Result{Status: health.Starting})
+			}
+			if state.probing || now.Before(state.next) {
+				continue
+			}
+			env, err := proc.Environ()
+			if err != nil {
+				log.Warnf("Could not probe proc %s due to %s.", proc.Identifier(), err)
+				state.next = now.Add(check.Interval)
+				continue
+			}
+			state.probing = true
+			go master.probe(proc, state, check, env)
+		}
+		master.Unlock()
+		time.Sleep(time.Second)
+	}
+}
+
+// probe will run check on proc and restart it through the normal restart path once it failed
+// check.Failures times in a row.
+func (master *Master) probe(proc process.ProcContainer, state *healthState, check health.Check, env []string) {
+	err := check.Probe(proc.GetCwd(), env)
+	master.Lock()
+	defer master.Unlock()
+	state.probing = false
+	state.next = time.Now().Add(check.Interval)
+	// the proc was deleted or restarted meanwhile
+	if master.Procs[proc.Identifier()] != proc || proc.GetPid() != state.pid {
+		return
+	}
+	result := proc.GetStatus().Health
+	result.CheckedAt = time.Now().Unix()
+	if err == nil {
+		result.Status = health.Healthy
+		result.Failures = 0
+		result.Output = ""
+		proc.SetHealth(result)
+		return
+	}
+	result.Status = health.Unhealthy
+	result.Failures++
+	result.Output = err.Error()
+	proc.SetHealth(result)
+	log.Warnf("Health check of proc %s failed (%d/%d): %s", proc.Identifier(), result.Failures, check.Failures, err)
+	if result.Failures < check.Failures {
+		return
+	}
+	log.Errorf("Proc %s failed %d health checks in a row, restarting it.", proc.Identifier(), result.Failures)
+	if err := master.restart(proc, fmt.Sprintf("%d failed health checks: %s", result.Failures, result.Output)); err != nil {
+		log.Warnf("Could not restart process %s due to %s.", proc.Identifier(), err)
+	}
+}
+
+// formatHealth will describe the outcome of the latest health probes for pmgo info.
+func formatHealth(result health.Result) string {
+	if result.Status == "" || result.Status == health.Starting {
+		return health.Starting
+	}
+	checkedAt := time.Unix(result.CheckedAt, 0).Format(time.RFC3339)
+	if result.Status == health.Healthy {
+		return fmt.Sprintf("%s (checked at %s)", result.Status, checkedAt)
+	}
+	return fmt.Sprintf("%s, %d failures in a row (checked at %s): %s", result.Status, result.Failures, checkedAt, result.Output)
+}
@@ -0,0 +1,49 @@
+package master
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/struCoder/pmgo/lib/health"
+)
+
+func TestHealthProbeFailures(t *testing.T) {
+	master := newTestMaster(t)
+	proc := newTestProc(t, master, "app", "exec sleep 30")
+	if err := master.start(proc); err != nil {
+		t.Fatal(err)
+	}
+	defer master.stop(proc)
+	pid := proc.GetPid()
+	state := &healthState{pid: pid}
+	failing := health.Check{Exec: "echo down; exit 1", Failures: 2}.WithDefaults()
+	passing := health.Check{Exec: "exit 0", Failures: 2}.WithDefaults()
+
+	master.probe(proc, state, failing, nil)
+	if result := proc.GetStatus().Health; result.Status != health.Unhealthy || result.Failures != 1 || !strings.Contains(result.Output, "down") {
+		t.Fatalf("unexpected result %+v after a failed probe", result)
+	}
+	master.probe(proc, state, passing, nil)
+	if result := proc.GetStatus().Health; result.Status != health.Healthy || result.Failures != 0 {
+		t.Fatalf("unexpected result %+v after a passing probe", result)
+	}
+
+	master.probe(proc, state, failing, nil)
+	if proc.GetPid() != pid {
+		t.Fatal("proc was restarted before reaching the failures in a row")
+	}
+	master.probe(proc, state, failing, nil)
+	if proc.GetPid() == pid || !proc.IsAlive() {
+		t.Fatal("proc was not restarted after failing the health checks")
+	}
+	history := proc.GetStatus().History
+	if proc.GetStatus().Restarts != 1 || !strings.HasPrefix(history[len(history)-1].Reason, "2 failed health checks") {
+		t.Fatalf("unexpected restart history %+v", history)
+	}
+
+	// a probe of the previous run is ignored
+	master.probe(proc, state, failing, nil)
+	if proc.GetStatus().Restarts != 1 {
+		t.Fatal("a probe of the previous run restarted the proc")
+	}
+}
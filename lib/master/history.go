@@ -0,0 +1,110 @@
+package master
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/struCoder/pmgo/lib/artifact"
+	"github.com/struCoder/pmgo/lib/process"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Rollback is a struct that represents the build a proc, or cluster, should be switched back to.
+type Rollback struct {
+	Name    string // Name is the proc or cluster name.
+	Version string // Version is the build to run. Empty is the build before the active one.
+}
+
+// HistoryResponse is the list of builds kept of a proc, or cluster.
+type HistoryResponse struct {
+	Name   string               // Name is the proc or cluster name.
+	Active string               // Active is the version of the build the proc runs.
+	Builds []*artifact.Artifact // Builds are the builds kept, newest first.
+}
+
+// History will return the builds kept of the proc, or cluster, named name.
+// Returns a tuple with the history and an error in case there's any.
+func (master *Master) History(name string) (*HistoryResponse, error) {
+	master.Lock()
+	defer master.Unlock()
+	procs := master.lookup(name)
+	if len(procs) == 0 {
+		return nil, ErrUnknownProcess
+	}
+	history, err := artifact.Load(artifact.Dir(procs[0].GetPath()))
+	if err != nil {
+		return nil, err
+	}
+	response := &HistoryResponse{Name: groupName(procs[0]), Active: history.Active}
+	for i := len(history.Artifacts) - 1; i >= 0; i-- {
+		response.Builds = append(response.Builds, history.Artifacts[i])
+	}
+	return response, nil
+}
+
+// RollbackProcess will switch the proc, or cluster, back to a previous build and restart it if it
+// is running. The builds kept are listed by History.
+// Returns a tuple with the version switched to and an error in case there's any.
+func (master *Master) RollbackProcess(rollback *Rollback) (string, error) {
+	master.Lock()
+	procs := master.lookup(rollback.Name)
+	if len(procs) == 0 {
+		master.Unlock()
+		return "", ErrUnknownProcess
+	}
+	name := groupName(procs[0])
+	version, err := master.activate(name, procs[0], rollback.Version)
+	if err != nil {
+		master.Unlock()
+		return "", err
+	}
+	running := false
+	for _, proc := range master.lookup(name) {
+		running = running || proc.IsAlive()
+	}
+	master.Unlock()
+
+	log.Infof("Proc %s rolled back to build %s", name, version)
+	if !running {
+		return version, nil
+	}
+	return version, master.restartProcess(name, "rollback to "+version)
+}
+
+// activate will link the binary of proc, on the group name, to the build version, or to the build
+// before the active one when version is empty.
+// NOT thread safe method. Lock should be acquired before calling it.
+func (master *Master) activate(name string, proc process.ProcContainer, version string) (string, error) {
+	dir := artifact.Dir(proc.GetPath())
+	history, err := artifact.Load(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(history.Artifacts) == 0 {
+		return "", fmt.Errorf("proc %s has no builds kept", name)
+	}
+	if version == "" {
+		previous := history.Previous()
+		if previous == nil {
+			return "", fmt.Errorf("proc %s has no build before %s", name, history.Active)
+		}
+		version = previous.Version
+	}
+	if version == history.Active {
+		return "", fmt.Errorf("proc %s already runs build %s", name, version)
+	}
+	if history.Find(version) == nil {
+		return "", fmt.Errorf("unknown build %s, see pmgo history %s", version, name)
+	}
+	return version, artifact.Activate(dir, version, path.Join(proc.GetPath(), name))
+}
+
+// activeBuild will return the version of the build proc runs, if any.
+func activeBuild(proc process.ProcContainer) string {
+	history, err := artifact.Load(artifact.Dir(proc.GetPath()))
+	if err != nil {
+		return ""
+	}
+	return history.Active
+}
@@ -0,0 +1,316 @@
+package master
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errNotFound           = errors.New("Not found.")
+	errMethodNotAllowed   = errors.New("Method not allowed.")
+	errMissingGoBinFields = errors.New("Name and SourcePath are required.")
+)
+
+// HTTPApi exports the RemoteMaster operations as a JSON REST API:
+//
+// - GET    /api/procs                     list every proc status (MonitStatus)
+// - POST   /api/procs                     build and start a GoBin (StartGoBin)
+// - GET    /api/procs/{name}              proc detail info (GetProcByName)
+// - DELETE /api/procs/{name}              delete a proc (DeleteProcess)
+// - POST   /api/procs/{name}/{action}     start, stop, restart, reload, rebuild or flush a proc
+// - PUT    /api/procs/{name}/instances    scale a cluster
+// - GET    /api/procs/{name}/history      list the builds kept of a proc (History)
+// - POST   /api/procs/{name}/rollback     switch a proc back to a previous build (RollbackProcess)
+// - POST   /api/logs                      read the procs logs (Logs)
+// - POST   /api/save                      save the list of procs (Save)
+// - POST   /api/resurrect                 restore the saved list of procs (Resurrect)
+// - GET    /api/openapi.json              the OpenAPI document of this API
+type HTTPApi struct {
+	remoteMaster *RemoteMaster
+}
+
+// httpError is the body of every failed request.
+type httpError struct {
+	Error  string `json:"error"`
+	Output string `json:"output,omitempty"`
+}
+
+// NewHTTPApi will create the REST API on top of remoteMaster.
+// Returns a HTTPApi instance.
+func NewHTTPApi(remoteMaster *RemoteMaster) *HTTPApi {
+	return &HTTPApi{remoteMaster: remoteMaster}
+}
+
+// Handler will return the http.Handler serving the API.
+func (api *HTTPApi) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/openapi.json", api.openAPI)
+	mux.HandleFunc("/api/procs", api.procs)
+	mux.HandleFunc("/api/procs/", api.proc)
+	mux.HandleFunc("/api/logs", api.logs)
+	mux.HandleFunc("/api/save", api.save)
+	mux.HandleFunc("/api/resurrect", api.resurrect)
+	return mux
+}
+
+// ListenAndServe will serve the API on addr, using TLS when tlsConfig is not nil. Addresses other
+// than loopback ones are refused unless clients authenticate with a token or a client certificate.
+// It only returns when the server fails.
+// Returns an error in case there's any.
+func (api *HTTPApi) ListenAndServe(addr string, tlsConfig *tls.Config) error {
+	master := api.remoteMaster.master
+	if err := master.checkExposed(addr); err != nil {
+		return err
+	}
+	log.Infof("Serving REST API on %s", addr)
+	server := &http.Server{Addr: addr, Handler: api.remoteMaster.master.authorize(api.Handler()), TLSConfig: tlsConfig}
+	if tlsConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+func (api *HTTPApi) openAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(openAPIDocument))
+}
+
+func (api *HTTPApi) procs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var response ProcResponse
+		if err := api.remoteMaster.MonitStatus("", &response); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	case http.MethodPost:
+		goBin := &GoBin{}
+		if err := json.NewDecoder(r.Body).Decode(goBin); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if goBin.Name == "" || goBin.SourcePath == "" {
+			writeError(w, http.StatusBadRequest, errMissingGoBinFields)
+			return
+		}
+		if api.remoteMaster.master.IsExistProc(goBin.Name) {
+			writeError(w, http.StatusConflict, ErrProcessExists)
+			return
+		}
+		var ack bool
+		if err := api.remoteMaster.StartGoBin(goBin, &ack); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, api.remoteMaster.master.ProcInfo(goBin.Name))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func (api *HTTPApi) proc(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/procs/"), "/"), "/")
+	name := parts[0]
+	if name == "" || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			var info map[string]string
+			api.remoteMaster.GetProcByName(name, &info)
+			if len(info) == 0 {
+				writeError(w, http.StatusNotFound, ErrUnknownProcess)
+				return
+			}
+			writeJSON(w, http.StatusOK, info)
+		case http.MethodDelete:
+			var ack bool
+			api.writeAck(w, api.remoteMaster.DeleteProcess(name, &ack))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		}
+		return
+	}
+
+	var ack bool
+	action := parts[1]
+	if action == "instances" {
+		if r.Method != http.MethodPut {
+			writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+			return
+		}
+		scale := &Scale{}
+		if err := json.NewDecoder(r.Body).Decode(scale); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		scale.Name = name
+		api.writeAck(w, api.remoteMaster.ScaleProcess(scale, &ack))
+		return
+	}
+	if action == "history" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+			return
+		}
+		var response HistoryResponse
+		if err := api.remoteMaster.History(name, &response); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	if action == "rollback" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+			return
+		}
+		// the body is optional, rolling back to the previous build
+		rollback := &Rollback{}
+		if err := json.NewDecoder(r.Body).Decode(rollback); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		rollback.Name = name
+		var version string
+		if err := api.remoteMaster.RollbackProcess(rollback, &version); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"version": version})
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	switch action {
+	case "start":
+		api.writeAck(w, api.remoteMaster.StartProcess(name, &ack))
+	case "stop":
+		api.writeAck(w, api.remoteMaster.StopProcess(name, &ack))
+	case "restart":
+		api.writeAck(w, api.remoteMaster.RestartProcess(name, &ack))
+	case "reload":
+		api.writeAck(w, api.remoteMaster.ReloadProcess(name, &ack))
+	case "rebuild":
+		api.writeAck(w, api.remoteMaster.RebuildProcess(name, &ack))
+	case "flush":
+		api.writeAck(w, api.remoteMaster.FlushProcess(name, &ack))
+	default:
+		writeError(w, http.StatusNotFound, errNotFound)
+	}
+}
+
+func (api *HTTPApi) logs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	request := &LogsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var response LogsResponse
+	if err := api.remoteMaster.Logs(request, &response); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (api *HTTPApi) save(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	var ack bool
+	api.writeAck(w, api.remoteMaster.Save("", &ack))
+}
+
+func (api *HTTPApi) resurrect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	var response ResurrectResponse
+	if err := api.remoteMaster.Resurrect("", &response); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (api *HTTPApi) writeAck(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ack": true})
+}
+
+// isLoopback will return true if the host of addr is localhost or a loopback IP. An empty host
+// listens on every interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// errorStatus will map err to the HTTP status code the API answers with.
+func errorStatus(err error) int {
+	switch err.(type) {
+	case *ValidationError:
+		return http.StatusBadRequest
+	case *BuildError:
+		return http.StatusUnprocessableEntity
+	}
+	switch err {
+	case ErrUnknownProcess:
+		return http.StatusNotFound
+	case ErrProcessExists:
+		return http.StatusConflict
+	case ErrStopping:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	body := httpError{Error: err.Error()}
+	if buildErr, ok := err.(*BuildError); ok {
+		body.Error = buildErr.Err.Error()
+		body.Output = string(buildErr.Output)
+	}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("Failed to write REST API response due to %s", err)
+	}
+}
@@ -0,0 +1,102 @@
+package master
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveAPI will send a request to the REST API of master.
+// Returns the response recorder.
+func serveAPI(master *Master, method string, url string, body string) *httptest.ResponseRecorder {
+	api := NewHTTPApi(&RemoteMaster{master: master})
+	recorder := httptest.NewRecorder()
+	api.Handler().ServeHTTP(recorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+	return recorder
+}
+
+func TestHTTPApiStatusCodes(t *testing.T) {
+	master := newTestMaster(t)
+	newTestProc(t, master, "app", "exit 0")
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		status int
+	}{
+		{name: "list procs", method: http.MethodGet, url: "/api/procs", status: http.StatusOK},
+		{name: "procs wrong method", method: http.MethodPut, url: "/api/procs", status: http.StatusMethodNotAllowed},
+		{name: "start invalid json", method: http.MethodPost, url: "/api/procs", body: "{", status: http.StatusBadRequest},
+		{name: "start missing fields", method: http.MethodPost, url: "/api/procs", body: "{}", status: http.StatusBadRequest},
+		{name: "start existing proc", method: http.MethodPost, url: "/api/procs", body: `{"Name":"app","SourcePath":"/tmp/app"}`, status: http.StatusConflict},
+		{name: "start invalid stop signal", method: http.MethodPost, url: "/api/procs", body: `{"Name":"new","SourcePath":"/tmp/new","StopSignal":"SIGNOPE"}`, status: http.StatusBadRequest},
+		{name: "proc info", method: http.MethodGet, url: "/api/procs/app", status: http.StatusOK},
+		{name: "unknown proc info", method: http.MethodGet, url: "/api/procs/missing", status: http.StatusNotFound},
+		{name: "delete unknown proc", method: http.MethodDelete, url: "/api/procs/missing", status: http.StatusNotFound},
+		{name: "proc wrong method", method: http.MethodPost, url: "/api/procs/app", status: http.StatusMethodNotAllowed},
+		{name: "unknown action", method: http.MethodPost, url: "/api/procs/app/bogus", status: http.StatusNotFound},
+		{name: "action wrong method", method: http.MethodGet, url: "/api/procs/app/stop", status: http.StatusMethodNotAllowed},
+		{name: "nested path", method: http.MethodPost, url: "/api/procs/app/stop/now", status: http.StatusNotFound},
+		{name: "action on unknown proc", method: http.MethodPost, url: "/api/procs/missing/start", status: http.StatusNotFound},
+		{name: "scale invalid json", method: http.MethodPut, url: "/api/procs/app/instances", body: "{", status: http.StatusBadRequest},
+		{name: "history wrong method", method: http.MethodPost, url: "/api/procs/app/history", status: http.StatusMethodNotAllowed},
+		{name: "openapi document", method: http.MethodGet, url: "/api/openapi.json", status: http.StatusOK},
+		{name: "openapi wrong method", method: http.MethodPost, url: "/api/openapi.json", status: http.StatusMethodNotAllowed},
+		{name: "logs invalid json", method: http.MethodPost, url: "/api/logs", body: "nope", status: http.StatusBadRequest},
+		{name: "logs wrong method", method: http.MethodGet, url: "/api/logs", status: http.StatusMethodNotAllowed},
+		{name: "save wrong method", method: http.MethodGet, url: "/api/save", status: http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveAPI(master, test.method, test.url, test.body)
+			if recorder.Code != test.status {
+				t.Fatalf("got status %d, want %d: %s", recorder.Code, test.status, recorder.Body.String())
+			}
+			if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Fatalf("got content type %q", contentType)
+			}
+		})
+	}
+}
+
+func TestHTTPApiStartStop(t *testing.T) {
+	t.Setenv("PMGO_TOKEN", "secret")
+	master := newTestMaster(t)
+	proc := newTestProc(t, master, "app", "exec sleep 30")
+	proc.Env = map[string]string{"PORT": "80"}
+
+	if recorder := serveAPI(master, http.MethodPost, "/api/procs/app/start", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("start: got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if !proc.IsAlive() {
+		t.Fatal("proc is not running after start")
+	}
+	var response ProcResponse
+	recorder := serveAPI(master, http.MethodGet, "/api/procs", "")
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Procs) != 1 || response.Procs[0].Name != "app" || response.Procs[0].Status.Status != "running" {
+		t.Fatalf("unexpected procs %s", recorder.Body.String())
+	}
+
+	if recorder := serveAPI(master, http.MethodPost, "/api/procs/app/stop", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("stop: got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	waitFor(t, 5*time.Second, "the proc to stop", func() bool { return !proc.IsAlive() })
+	var info map[string]string
+	recorder = serveAPI(master, http.MethodGet, "/api/procs/app", "")
+	if err := json.NewDecoder(recorder.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info["status"] != "stopped" {
+		t.Fatalf("got status %q after stop", info["status"])
+	}
+	if info["env"] != "PORT=80" {
+		t.Fatalf("got env %q, the daemon environment must not be shown", info["env"])
+	}
+}
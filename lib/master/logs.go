@@ -0,0 +1,296 @@
+package master
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/struCoder/pmgo/lib/logrotate"
+)
+
+// maxLogsRead is the maximum amount of bytes read from a single file on each Logs call,
+// so a follower catching up on a big file does not get it in one single response.
+const maxLogsRead = 1024 * 1024
+
+// LogsRequest is a struct that represents which logs a client wants to read.
+type LogsRequest struct {
+	Names   []string          // Names are the procs whose logs will be read. All procs when empty.
+	Lines   int               // Lines is the number of lines returned from the end of a file read for the first time.
+	ErrOnly bool              // ErrOnly will only read the err files.
+	Offsets map[string]int64  // Offsets is where each file was left on the previous call, as returned by it.
+	Inodes  map[string]uint64 // Inodes are the inodes of the files Offsets refer to, as returned by the previous call.
+}
+
+// LogLine is a single line read from a proc out or err file.
+type LogLine struct {
+	Name string // Name is the proc name.
+	Err  bool   // Err is true if the line was read from the err file.
+	Text string // Text is the line without its trailing new line.
+}
+
+// LogsResponse is a struct with the lines read and where each file was left, so the
+// next request can follow the files from there.
+type LogsResponse struct {
+	Lines   []*LogLine
+	Offsets map[string]int64
+	Inodes  map[string]uint64
+}
+
+// logFile is a file read by Logs, with the writer the daemon copies the proc output through.
+type logFile struct {
+	name   string
+	err    bool
+	path   string
+	writer *logrotate.Writer // writer is nil until the proc is started.
+}
+
+// fileLine is a line of a log file and the offset it starts at, -1 on rotated files.
+type fileLine struct {
+	text   string
+	offset int64
+}
+
+// Logs will read the new lines of the out and err files of the procs on request, in the order the
+// daemon read them from the procs as far as it remembers. Files rotated since the previous call
+// are read to their end first, files flushed since then are read from the start.
+// Returns a tuple with the lines read and an error in case there's any.
+func (master *Master) Logs(request *LogsRequest) (*LogsResponse, error) {
+	master.Lock()
+	wanted := make(map[string]bool)
+	for _, name := range request.Names {
+		named := master.lookup(name)
+		if len(named) == 0 {
+			master.Unlock()
+			return nil, ErrUnknownProcess
+		}
+		for _, proc := range named {
+			wanted[proc.Identifier()] = true
+		}
+	}
+	files := []*logFile{}
+	for _, proc := range master.ListProcs() {
+		if len(wanted) > 0 && !wanted[proc.Identifier()] {
+			continue
+		}
+		outLog, errLog := proc.GetLogWriters()
+		if !request.ErrOnly {
+			files = append(files, &logFile{name: proc.Identifier(), path: proc.GetOutFile(), writer: outLog})
+		}
+		files = append(files, &logFile{name: proc.Identifier(), err: true, path: proc.GetErrFile(), writer: errLog})
+	}
+	master.Unlock()
+
+	type sequencedLine struct {
+		sequence uint64
+		line     *LogLine
+	}
+	lines := []*sequencedLine{}
+	response := &LogsResponse{Offsets: make(map[string]int64), Inodes: make(map[string]uint64)}
+	for _, file := range files {
+		var read []fileLine
+		var err error
+		offset, known := request.Offsets[file.path]
+		inode := request.Inodes[file.path]
+		if known {
+			read, offset, inode, err = file.follow(offset, inode)
+		} else {
+			read, offset, inode, err = file.tail(request.Lines)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		response.Offsets[file.path] = offset
+		response.Inodes[file.path] = inode
+		for _, line := range read {
+			// lines written before the writer was opened, or rotated, come first
+			var sequence uint64
+			if file.writer != nil && line.offset >= 0 {
+				sequence = file.writer.Sequence(line.offset)
+			}
+			lines = append(lines, &sequencedLine{
+				sequence: sequence,
+				line:     &LogLine{Name: file.name, Err: file.err, Text: line.text},
+			})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].sequence < lines[j].sequence
+	})
+	response.Lines = make([]*LogLine, 0, len(lines))
+	for _, line := range lines {
+		response.Lines = append(response.Lines, line.line)
+	}
+	return response, nil
+}
+
+// follow will read the complete lines of the file from offset. When the file is not inode anymore,
+// the file it was rotated to is read to its end first, then the new file from its start.
+// Returns the lines, where the file was left, its inode and an error in case there's any.
+func (file *logFile) follow(offset int64, inode uint64) ([]fileLine, int64, uint64, error) {
+	f, err := os.Open(file.path)
+	if err != nil {
+		return nil, offset, inode, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, offset, inode, err
+	}
+	current := logrotate.Inode(info)
+	lines := []fileLine{}
+	if inode != 0 && current != inode {
+		if rotated, ok := file.rotated(inode); ok {
+			read, next, done, err := readRotated(rotated, offset)
+			if err == nil && !done {
+				return read, next, inode, nil
+			}
+			lines = read
+		}
+		offset = 0
+	}
+	read, offset, err := readLinesFrom(f, info.Size(), offset)
+	return append(lines, read...), offset, current, err
+}
+
+// tail will read the last n complete lines of the file.
+// Returns the lines, where the file was left, its inode and an error in case there's any.
+func (file *logFile) tail(n int) ([]fileLine, int64, uint64, error) {
+	f, err := os.Open(file.path)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	lines, offset, err := tailLines(f, info.Size(), n)
+	return lines, offset, logrotate.Inode(info), err
+}
+
+// rotated will return the name the file with inode was rotated to, if the daemon remembers it.
+func (file *logFile) rotated(inode uint64) (string, bool) {
+	if file.writer == nil {
+		return "", false
+	}
+	return file.writer.Rotated(inode)
+}
+
+// readLinesFrom will read the complete lines of file, of size bytes, starting at offset. A file
+// smaller than offset was flushed and is read from its start. A line longer than maxLogsRead is
+// returned in parts, so following the file goes on past it.
+// Returns the lines, the offset right after the last line read and an error in case there's any.
+func readLinesFrom(file *os.File, size int64, offset int64) ([]fileLine, int64, error) {
+	if size < offset {
+		offset = 0
+	}
+	buf := make([]byte, min64(size-offset, maxLogsRead))
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, offset, err
+	}
+	buf = buf[:n]
+	last := bytes.LastIndexByte(buf, '\n')
+	if last < 0 && n == maxLogsRead {
+		return []fileLine{{text: string(buf), offset: offset}}, offset + int64(n), nil
+	}
+	if last < 0 {
+		return nil, offset, nil
+	}
+	return splitLines(buf[:last], offset), offset + int64(last) + 1, nil
+}
+
+// tailLines will read the last n complete lines of file, of size bytes.
+// Returns the lines, the offset right after the last complete line and an error in case there's any.
+func tailLines(file *os.File, size int64, n int) ([]fileLine, int64, error) {
+	start := size - min64(size, maxLogsRead)
+	buf := make([]byte, size-start)
+	read, err := file.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	buf = buf[:read]
+	last := bytes.LastIndexByte(buf, '\n')
+	if last < 0 {
+		return nil, start, nil
+	}
+	lines := splitLines(buf[:last], start)
+	if start > 0 && len(lines) > 0 {
+		// the first line is most likely cut in half
+		lines = lines[1:]
+	}
+	if n >= 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, start + int64(last) + 1, nil
+}
+
+// readRotated will read the lines of the rotated file filename, gzipped when suffixed with .gz,
+// from offset. Rotated files are complete, so their last line is read even without a new line.
+// Returns the lines, where the file was left, true when its end was reached and an error in case there's any.
+func readRotated(filename string, offset int64) ([]fileLine, int64, bool, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, offset, true, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(filename, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, offset, true, err
+		}
+		defer gz.Close()
+		if _, err := io.CopyN(ioutil.Discard, gz, offset); err != nil {
+			return nil, offset, true, err
+		}
+		reader = gz
+	} else if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, true, err
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(reader, maxLogsRead+1))
+	if err != nil {
+		return nil, offset, true, err
+	}
+	if len(buf) <= maxLogsRead {
+		buf = bytes.TrimSuffix(buf, []byte{'\n'})
+		if len(buf) == 0 {
+			return nil, offset, true, nil
+		}
+		return rotatedLines(splitLines(buf, offset)), offset + int64(len(buf)), true, nil
+	}
+	buf = buf[:maxLogsRead]
+	if last := bytes.LastIndexByte(buf, '\n'); last >= 0 {
+		buf = buf[:last+1]
+	}
+	return rotatedLines(splitLines(bytes.TrimSuffix(buf, []byte{'\n'}), offset)), offset + int64(len(buf)), false, nil
+}
+
+// rotatedLines will mark lines as read from a rotated file, whose writes are not remembered.
+func rotatedLines(lines []fileLine) []fileLine {
+	for i := range lines {
+		lines[i].offset = -1
+	}
+	return lines
+}
+
+// splitLines will split buf, read at offset of its file, on new lines.
+func splitLines(buf []byte, offset int64) []fileLine {
+	lines := []fileLine{}
+	for _, line := range bytes.Split(buf, []byte{'\n'}) {
+		lines = append(lines, fileLine{text: string(bytes.TrimSuffix(line, []byte{'\r'})), offset: offset})
+		offset += int64(len(line)) + 1
+	}
+	return lines
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,91 @@
+package master
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadLinesFromLongLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "app.out")
+	long := strings.Repeat("x", maxLogsRead+10)
+	if err := ioutil.WriteFile(filename, []byte(long+"\nnext\npartial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, _ := file.Stat()
+
+	texts := []string{}
+	offset := int64(0)
+	for i := 0; i < 3; i++ {
+		lines, next, err := readLinesFrom(file, info.Size(), offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range lines {
+			texts = append(texts, line.text)
+		}
+		offset = next
+	}
+	want := []string{long[:maxLogsRead], long[maxLogsRead:], "next"}
+	if !reflect.DeepEqual(texts, want) {
+		t.Fatalf("got %d lines %.20q, want %d lines %.20q", len(texts), texts, len(want), want)
+	}
+	if wantOffset := info.Size() - int64(len("partial")); offset != wantOffset {
+		t.Fatalf("got offset %d, want %d before the incomplete line", offset, wantOffset)
+	}
+}
+
+func TestLogsFollowAcrossRotation(t *testing.T) {
+	master := newTestMaster(t)
+	proc := newTestProc(t, master, "app", "echo one; echo two; exec sleep 30")
+	if err := master.start(proc); err != nil {
+		t.Fatal(err)
+	}
+	defer master.stop(proc)
+
+	read := func(request *LogsRequest) (*LogsResponse, []string) {
+		response, err := master.Logs(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts := []string{}
+		for _, line := range response.Lines {
+			texts = append(texts, line.Text)
+		}
+		return response, texts
+	}
+	var response *LogsResponse
+	var texts []string
+	waitFor(t, 5*time.Second, "the proc output", func() bool {
+		response, texts = read(&LogsRequest{Names: []string{"app"}, Lines: -1})
+		return len(texts) == 2
+	})
+	if want := []string{"one", "two"}; !reflect.DeepEqual(texts, want) {
+		t.Fatalf("got lines %q, want %q", texts, want)
+	}
+
+	outLog, _ := proc.GetLogWriters()
+	outLog.Write([]byte("three\n"))
+	if err := outLog.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	outLog.Write([]byte("four\n"))
+	_, texts = read(&LogsRequest{Names: []string{"app"}, Offsets: response.Offsets, Inodes: response.Inodes})
+	if want := []string{"three", "four"}; !reflect.DeepEqual(texts, want) {
+		t.Fatalf("got lines %q across the rotation, want %q", texts, want)
+	}
+}
@@ -3,7 +3,7 @@ Master package is the main package that keeps everything running as it should be
 
 RemoteMaster is responsible for exporting the main pmgo operations as HTTP requests. If you want to start a Remote Server, run:
 
-- remoteServer := master.StartRemoteMasterServer(dsn, configFile)
+- remoteServer := master.StartRemoteMasterServer(configFile)
 
 It will start a remote master and return the instance.
 
@@ -13,7 +13,7 @@ To make remote requests, use the Remote Client by instantiating using:
 
 It will start the remote client and return the instance so you can use to initiate requests, such as:
 
-- remoteClient.StartGoBin(sourcePath, name, keepAlive, args)
+- remoteClient.StartGoBin(goBin)
 */
 
 package master
@@ -23,10 +23,18 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 
 	"time"
 
+	"github.com/robfig/cron/v3"
+	"github.com/struCoder/pmgo/lib/cgroup"
+	"github.com/struCoder/pmgo/lib/logrotate"
 	"github.com/struCoder/pmgo/lib/preparable"
 	"github.com/struCoder/pmgo/lib/process"
 	"github.com/struCoder/pmgo/lib/utils"
@@ -35,6 +43,34 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+var (
+	// ErrUnknownProcess is returned when there is no proc, nor cluster, with the given name.
+	ErrUnknownProcess = errors.New("Unknown process.")
+	// ErrProcessExists is returned when starting a proc with the name of an existing one.
+	ErrProcessExists = errors.New("Trying to start a process that already exist.")
+	// ErrStopping is returned when starting a proc while pmgo is stopping.
+	ErrStopping = errors.New("pmgo is stopping.")
+)
+
+// BuildError is returned when the binary of a proc could not be built.
+type BuildError struct {
+	Err    error  // Err is the build command error.
+	Output []byte // Output is the build command output.
+}
+
+func (err *BuildError) Error() string {
+	return fmt.Sprintf("ERROR: %s OUTPUT: %s", err.Err, string(err.Output))
+}
+
+// ValidationError is returned by Prepare when the GoBin settings are invalid, before anything is built.
+type ValidationError struct {
+	Err error // Err is the invalid setting.
+}
+
+func (err *ValidationError) Error() string {
+	return err.Err.Error()
+}
+
 // Master is the main module that keeps everything in place and execute
 // the necessary actions to keep the process running as they should be.
 type Master struct {
@@ -46,7 +82,28 @@ type Master struct {
 	ErrFile   string           // ErrFile is the pmgo err log file path.
 	Watcher   *watcher.Watcher // Watcher is a watcher instance.
 
+	LogRotate     logrotate.Config      // LogRotate is the default log rotation for procs that don't set their own.
+	RestartPolicy process.RestartPolicy // RestartPolicy is the default restart policy for procs that don't set their own.
+	TCPAddr       string                // TCPAddr is the address the daemon also listens on for the cli, reached with --dns. Empty disables it.
+	HTTPAddr      string                // HTTPAddr is the address the REST API listens on. Empty disables it.
+	MetricsAddr   string                // MetricsAddr is the address the Prometheus /metrics endpoint listens on. Empty disables it.
+	AllowedUIDs   []int                 // AllowedUIDs are the users, besides the daemon one, allowed on the unix socket. Empty skips the check.
+	KeepBuilds    int                   // KeepBuilds is the default number of builds kept per proc for rollbacks. Defaults to 5.
+
+	TLSCertFile     string // TLSCertFile is the certificate of the TCP listeners. Empty disables TLS.
+	TLSKeyFile      string // TLSKeyFile is the TLSCertFile key.
+	TLSClientCAFile string // TLSClientCAFile is the CA client certificates must be signed by. Empty disables mutual TLS.
+	TokenFile       string // TokenFile lists the tokens accepted on the TCP listeners, one per line. Empty disables token auth.
+
 	Procs map[string]process.ProcContainer // Procs is a map containing all procs started on pmgo.
+
+	crashes   map[string]*crashState  // crashes keeps track of the procs recent crashes.
+	health    map[string]*healthState // health keeps track of the procs health probes.
+	memory    map[string]*memoryState // memory keeps track of the procs memory samples.
+	cron      map[string]*cronState   // cron keeps track of the procs scheduled restarts.
+	sources   map[string]*sourceState // sources keeps track of the watched source trees, by proc or cluster name.
+	startTime time.Time               // startTime is when the daemon was started.
+	stopping  bool                    // stopping is set by Stop, procs are not started nor restarted anymore then.
 }
 
 // DecodableMaster is a struct that the config toml file will decode to.
@@ -60,6 +117,19 @@ type DecodableMaster struct {
 
 	Watcher *watcher.Watcher
 
+	LogRotate     logrotate.Config
+	RestartPolicy process.RestartPolicy
+	TCPAddr       string
+	HTTPAddr      string
+	MetricsAddr   string
+	AllowedUIDs   []int
+	KeepBuilds    int
+
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	TokenFile       string
+
 	Procs map[string]*process.Proc
 }
 
@@ -81,12 +151,31 @@ func InitMaster(configFile string) *Master {
 	}
 	// We need this hack because toml decoder doesn't decode to interfaces
 	master := &Master{
-		SysFolder: decodableMaster.SysFolder,
-		PidFile:   decodableMaster.PidFile,
-		OutFile:   decodableMaster.OutFile,
-		ErrFile:   decodableMaster.ErrFile,
-		Watcher:   decodableMaster.Watcher,
+		SysFolder:     decodableMaster.SysFolder,
+		PidFile:       decodableMaster.PidFile,
+		OutFile:       decodableMaster.OutFile,
+		ErrFile:       decodableMaster.ErrFile,
+		Watcher:       decodableMaster.Watcher,
+		LogRotate:     decodableMaster.LogRotate,
+		RestartPolicy: decodableMaster.RestartPolicy,
+		TCPAddr:       decodableMaster.TCPAddr,
+		HTTPAddr:      decodableMaster.HTTPAddr,
+		MetricsAddr:   decodableMaster.MetricsAddr,
+		AllowedUIDs:   decodableMaster.AllowedUIDs,
+		KeepBuilds:    decodableMaster.KeepBuilds,
+
+		TLSCertFile:     decodableMaster.TLSCertFile,
+		TLSKeyFile:      decodableMaster.TLSKeyFile,
+		TLSClientCAFile: decodableMaster.TLSClientCAFile,
+		TokenFile:       decodableMaster.TokenFile,
+
 		Procs:     procs,
+		crashes:   make(map[string]*crashState),
+		health:    make(map[string]*healthState),
+		memory:    make(map[string]*memoryState),
+		cron:      make(map[string]*cronState),
+		sources:   make(map[string]*sourceState),
+		startTime: time.Now(),
 	}
 
 	if master.SysFolder == "" {
@@ -94,18 +183,37 @@ func InitMaster(configFile string) *Master {
 		master.SysFolder = path.Dir(configFile) + "/"
 	}
 	master.Watcher = watcher
+	if err := cgroup.Setup(); err != nil {
+		log.Warnf("Cgroup limits are disabled: %s", err)
+	}
 	master.Revive()
 	log.Infof("All procs revived...")
 	go master.WatchProcs()
 	// go master.SaveProcsLoop()
 	go master.UpdateStatus()
+	go master.HealthChecks()
+	go master.MemoryChecks()
+	go master.CronRestarts()
+	go master.SourceWatches()
 	return master
 }
 
-// ProcInfo will return process detail info with map
+// ProcInfo will return process detail info with map. For a cluster it returns its instances.
 func (master *Master) ProcInfo(proName string) map[string]string {
+	master.Lock()
+	defer master.Unlock()
 	proc := master.Procs[proName]
 	procDetailInfo := make(map[string]string)
+	if proc == nil {
+		if instances := master.instances(proName); len(instances) > 0 {
+			names := []string{}
+			for _, instance := range instances {
+				names = append(names, instance.Identifier())
+			}
+			procDetailInfo["name"] = proName
+			procDetailInfo["instances"] = strings.Join(names, "\n")
+		}
+	}
 	if proc != nil {
 		procStatus := proc.GetStatus()
 		procDetailInfo["pid"] = fmt.Sprintf("%d", proc.GetPid())
@@ -113,60 +221,277 @@ func (master *Master) ProcInfo(proName string) map[string]string {
 		procDetailInfo["pidFile"] = proc.GetPidFile()
 		procDetailInfo["errorFile"] = proc.GetErrFile()
 		procDetailInfo["path"] = proc.GetPath()
+		procDetailInfo["cwd"] = proc.GetCwd()
 		procDetailInfo["name"] = proc.GetName()
 		procDetailInfo["uptime"] = procStatus.Uptime
 		procDetailInfo["status"] = procStatus.Status
 		procDetailInfo["restart"] = fmt.Sprintf("%d", procStatus.Restarts)
+		procDetailInfo["lastExitCode"] = fmt.Sprintf("%d", procStatus.ExitCode)
+		if maxMemory, samples := proc.GetMaxMemory(); maxMemory > 0 {
+			procDetailInfo["maxMemory"] = formatMaxMemory(maxMemory, samples)
+		}
+		procDetailInfo["runtime"] = formatRuntime(proc)
+		if limits := proc.GetCgroup(); limits.Enabled() {
+			procDetailInfo["cgroupLimits"] = limits.String()
+		}
+		if usage, err := cgroup.ReadUsage(proc.Identifier()); err == nil {
+			procDetailInfo["cgroupUsage"] = usage.String()
+		}
+		if credential := proc.GetCredential(); credential != "" {
+			procDetailInfo["user"] = credential
+		}
+		if sourcePath := proc.GetSourcePath(); sourcePath != "" {
+			procDetailInfo["sourcePath"] = sourcePath
+		}
+		if runtime, _ := proc.GetRuntime(); runtime == process.RuntimeGo {
+			procDetailInfo["build"] = proc.GetBuild().String()
+		}
+		if version := activeBuild(proc); version != "" {
+			procDetailInfo["version"] = version
+		}
+		if watch := proc.GetSourceWatch(); watch.Enabled {
+			procDetailInfo["watch"] = formatSourceWatch(watch)
+		}
+		if spec := proc.GetCronRestart(); spec != "" {
+			procDetailInfo["cronRestart"] = master.formatCronRestart(proc)
+		}
+		if history := procStatus.History; len(history) > 0 {
+			procDetailInfo["restartHistory"] = formatHistory(history)
+		}
+		if check := proc.GetHealthCheck(); check.Enabled() {
+			procDetailInfo["healthCheck"] = check.String()
+			procDetailInfo["health"] = formatHealth(procStatus.Health)
+		}
+		if proc.GetCluster() != "" {
+			procDetailInfo["cluster"] = proc.GetCluster()
+			procDetailInfo["instanceId"] = strconv.Itoa(proc.GetInstanceID())
+		}
+		procDetailInfo["children"] = formatChildren(proc)
+		procDetailInfo["sockets"] = strings.Join(proc.GetSockets(), "\n")
+		procDetailInfo["stopSignal"] = proc.GetStopSignal()
+		procDetailInfo["killTimeout"] = proc.GetKillTimeout().String()
+		procDetailInfo["restartPolicy"] = formatRestartPolicy(proc.GetRestartPolicy().WithDefaults())
+		procDetailInfo["cleanEnv"] = strconv.FormatBool(proc.GetCleanEnv())
+		procDetailInfo["env"] = formatEnv(proc)
 	}
 
 	return procDetailInfo
 }
 
-// WatchProcs will keep the procs running forever.
+// formatRuntime will return the runtime of proc, with its interpreter if any.
+func formatRuntime(proc process.ProcContainer) string {
+	runtime, interpreter := proc.GetRuntime()
+	if interpreter != "" {
+		return runtime + " " + interpreter
+	}
+	return runtime
+}
+
+// formatChildren will return the process tree under proc, one "pid command" per line indented by depth.
+func formatChildren(proc process.ProcContainer) string {
+	children, err := proc.GetChildren()
+	if err != nil {
+		return err.Error()
+	}
+	lines := []string{}
+	var walk func(nodes []*process.ProcessNode, depth int)
+	walk = func(nodes []*process.ProcessNode, depth int) {
+		for _, node := range nodes {
+			lines = append(lines, fmt.Sprintf("%s%d %s", strings.Repeat("  ", depth), node.Pid, node.Command))
+			walk(node.Children, depth+1)
+		}
+	}
+	walk(children, 0)
+	return strings.Join(lines, "\n")
+}
+
+// formatEnv will return the variables pmgo set on proc when it was last started, one KEY=VALUE per
+// line, or the ones it sets when it was not started by this daemon yet. The daemon environment,
+// which may hold its secrets, is never shown.
+func formatEnv(proc process.ProcContainer) string {
+	if environ := proc.GetEnviron(); environ != nil {
+		return strings.Join(environ, "\n")
+	}
+	env, err := proc.GetEnv()
+	if err != nil {
+		return err.Error()
+	}
+	lines := make([]string, 0, len(env))
+	for k, v := range env {
+		lines = append(lines, k+"="+v)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func formatRestartPolicy(policy process.RestartPolicy) string {
+	maxRestarts := "unlimited"
+	if allowed := policy.RestartsAllowed(); allowed >= 0 {
+		maxRestarts = fmt.Sprintf("%d within %s", allowed, policy.Window)
+	}
+	jitter := 0.0
+	if *policy.Jitter > 0 {
+		jitter = *policy.Jitter
+	}
+	return fmt.Sprintf("delay %s up to %s (jitter %.0f%%), stable after %s, max restarts %s",
+		policy.Delay, policy.MaxDelay, jitter*100, policy.MinUptime, maxRestarts)
+}
+
+func formatHistory(history []process.RestartEvent) string {
+	lines := []string{}
+	for _, event := range history {
+		lines = append(lines, fmt.Sprintf("%s %s", time.Unix(event.Time, 0).Format(time.RFC3339), event.Reason))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WatchProcs will keep the procs running forever, restarting them with backoff
+// according to their restart policy.
 func (master *Master) WatchProcs() {
-	for proc := range master.Watcher.RestartProc() {
+	for dead := range master.Watcher.RestartProc() {
+		proc := dead.Proc
 		if !proc.ShouldKeepAlive() {
 			master.Lock()
+			recordExit(proc, dead.State)
 			master.updateStatus(proc)
 			master.Unlock()
 			log.Infof("Proc %s does not have keep alive set. Will not be restarted.", proc.Identifier())
 			continue
 		}
-		log.Infof("Restarting proc %s.", proc.Identifier())
 		if proc.IsAlive() {
 			log.Warnf("Proc %s was supposed to be dead, but it is alive.", proc.Identifier())
 		}
 		master.Lock()
-		err := master.restart(proc)
-		master.Unlock()
-		if err != nil {
-			log.Warnf("Could not restart process %s due to %s.", proc.Identifier(), err)
+		recordExit(proc, dead.State)
+		// the procs are left dead while pmgo stops
+		if !master.stopping {
+			master.scheduleRestart(proc)
 		}
+		master.Unlock()
 	}
 }
 
 // Prepare will compile the source code into a binary and return a preparable
-// ready to be executed.
-func (master *Master) Prepare(sourcePath string, name string, language string, keepAlive bool, args []string) (preparable.ProcPreparable, []byte, error) {
+// ready to be executed. Invalid settings are returned as a ValidationError.
+func (master *Master) Prepare(goBin *GoBin, language string) (preparable.ProcPreparable, []byte, error) {
+	if goBin.Runtime != "" {
+		language = goBin.Runtime
+	}
+	if err := validate(goBin, language); err != nil {
+		return nil, nil, &ValidationError{Err: err}
+	}
+	cwd := goBin.Cwd
+	if cwd == "" {
+		cwd = goBin.SourcePath
+	}
+	if goBin.Cwd == "" && language != process.RuntimeGo {
+		// SourcePath is the binary or script itself
+		cwd = path.Dir(goBin.SourcePath)
+	}
+	logRotate := master.LogRotate
+	if goBin.LogRotate != nil {
+		logRotate = *goBin.LogRotate
+	}
+	restartPolicy := master.RestartPolicy
+	if goBin.RestartPolicy != nil {
+		restartPolicy = *goBin.RestartPolicy
+	}
+	keepBuilds := goBin.KeepBuilds
+	if keepBuilds == 0 {
+		keepBuilds = master.KeepBuilds
+	}
 	procPreparable := &preparable.Preparable{
-		Name:       name,
-		SourcePath: sourcePath,
+		Name:       goBin.Name,
+		SourcePath: goBin.SourcePath,
 		SysFolder:  master.SysFolder,
 		Language:   language,
-		KeepAlive:  keepAlive,
-		Args:       args,
+		KeepAlive:  goBin.KeepAlive,
+		Args:       goBin.Args,
+		Env:        goBin.Env,
+		EnvFiles:   goBin.EnvFiles,
+		CleanEnv:   goBin.CleanEnv,
+		Cwd:        cwd,
+		LogRotate:  logRotate,
+
+		RestartPolicy: restartPolicy,
+		StopSignal:    goBin.StopSignal,
+		KillTimeout:   goBin.KillTimeout,
+		Sockets:       goBin.Sockets,
+		Instances:     goBin.Instances,
+		HealthCheck:   goBin.HealthCheck,
+
+		MaxMemory:        goBin.MaxMemory,
+		MaxMemorySamples: goBin.MaxMemorySamples,
+		CronRestart:      goBin.CronRestart,
+		SourceWatch:      goBin.SourceWatch,
+		Build:            goBin.Build,
+		KeepBuilds:       keepBuilds,
+
+		User:   goBin.User,
+		Group:  goBin.Group,
+		Groups: goBin.Groups,
+		Cgroup: goBin.Cgroup,
+
+		Interpreter: goBin.Interpreter,
+		Copy:        goBin.Copy,
+	}
+	if procPreparable.Instances < 0 {
+		procPreparable.Instances = runtime.NumCPU()
 	}
 	output, err := procPreparable.PrepareBin()
 	return procPreparable, output, err
 }
 
+// validate will check the settings of goBin started on the runtime language.
+// Returns an error in case there's any.
+func validate(goBin *GoBin, language string) error {
+	switch language {
+	case process.RuntimeGo, process.RuntimeExec, process.RuntimeInterpreter:
+	default:
+		return fmt.Errorf("unknown runtime %s, use go, exec or interpreter", language)
+	}
+	if language != process.RuntimeGo && goBin.SourceWatch.Enabled {
+		return errors.New("watch mode is only supported on the go runtime")
+	}
+	if language != process.RuntimeGo && !goBin.Build.IsZero() {
+		return errors.New("build options are only supported on the go runtime")
+	}
+	if err := goBin.Build.Validate(); err != nil {
+		return err
+	}
+	if goBin.KeepBuilds < 0 {
+		return errors.New("the number of builds kept can't be negative")
+	}
+	if _, err := process.ParseSignal(goBin.StopSignal); err != nil {
+		return err
+	}
+	if err := goBin.HealthCheck.Validate(); err != nil {
+		return err
+	}
+	if goBin.CronRestart != "" {
+		if _, err := cron.ParseStandard(goBin.CronRestart); err != nil {
+			return fmt.Errorf("invalid cron restart %q: %s", goBin.CronRestart, err)
+		}
+	}
+	if _, err := process.Credential(goBin.User, goBin.Group, goBin.Groups); err != nil {
+		return err
+	}
+	return goBin.Cgroup.Validate()
+}
+
 // RunPreparable will run procPreparable and add it to the watch list in case everything goes well.
 func (master *Master) RunPreparable(procPreparable preparable.ProcPreparable) error {
 	master.Lock()
 	defer master.Unlock()
-	if _, ok := master.Procs[procPreparable.Identifier()]; ok {
+	if master.stopping {
+		return ErrStopping
+	}
+	if len(master.lookup(procPreparable.Identifier())) > 0 {
 		log.Warnf("Proc %s already exist.", procPreparable.Identifier())
-		return errors.New("Trying to start a process that already exist.")
+		return ErrProcessExists
+	}
+	if procPreparable.GetInstances() > 0 {
+		return master.runInstances(procPreparable)
 	}
 	proc, err := procPreparable.Start()
 	if err != nil {
@@ -179,63 +504,123 @@ func (master *Master) RunPreparable(procPreparable preparable.ProcPreparable) er
 	return nil
 }
 
-// ListProcs will return a list of all procs.
+// ListProcs will return a list of all procs sorted by name.
 func (master *Master) ListProcs() []process.ProcContainer {
 	procsList := []process.ProcContainer{}
 	for _, v := range master.Procs {
 		procsList = append(procsList, v)
 	}
+	sort.Slice(procsList, func(i, j int) bool {
+		return procsList[i].Identifier() < procsList[j].Identifier()
+	})
 	return procsList
 }
 
-// RestartProcess will restart a process.
+// RestartProcess will restart a process, or every instance of a cluster. This is the only way to
+// run an errored process again.
 func (master *Master) RestartProcess(name string) error {
-	if proc, ok := master.Procs[name]; ok {
-		master.Lock()
-		err := master.restart(proc)
-		master.Unlock()
-		return err
+	return master.restartProcess(name, "restart requested")
+}
+
+// restartProcess will restart a process, or every instance of a cluster, recording reason on their history.
+func (master *Master) restartProcess(name string, reason string) error {
+	master.Lock()
+	defer master.Unlock()
+	procs := master.lookup(name)
+	if len(procs) == 0 {
+		return ErrUnknownProcess
+	}
+	for _, proc := range procs {
+		master.resetCrashState(proc)
+		if err := master.restart(proc, reason); err != nil {
+			return err
+		}
 	}
-	return errors.New("unknow process.")
+	return nil
+}
 
+// ReloadProcess will start a new instance of a process before stopping the current one,
+// handing it the listening sockets so no connection is dropped. Clusters are reloaded one instance at a time.
+func (master *Master) ReloadProcess(name string) error {
+	master.Lock()
+	defer master.Unlock()
+	procs := master.lookup(name)
+	if len(procs) == 0 {
+		return ErrUnknownProcess
+	}
+	for _, proc := range procs {
+		master.resetCrashState(proc)
+		if err := master.reload(proc); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// StartProcess will a start a process.
+// StartProcess will a start a process, or every instance of a cluster.
 func (master *Master) StartProcess(name string) error {
 	master.Lock()
 	defer master.Unlock()
-	if proc, ok := master.Procs[name]; ok {
-		return master.start(proc)
+	procs := master.lookup(name)
+	if len(procs) == 0 {
+		return ErrUnknownProcess
 	}
-	return errors.New("Unknown process.")
+	for _, proc := range procs {
+		master.resetCrashState(proc)
+		if err := master.start(proc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlushProcess will truncate the out and err files of the process, or of every instance of the cluster, with the given name.
+func (master *Master) FlushProcess(name string) error {
+	master.Lock()
+	defer master.Unlock()
+	procs := master.lookup(name)
+	if len(procs) == 0 {
+		return ErrUnknownProcess
+	}
+	for _, proc := range procs {
+		if err := proc.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// StopProcess will stop a process with the given name.
+// StopProcess will stop a process, or every instance of a cluster, with the given name.
 func (master *Master) StopProcess(name string) error {
 	master.Lock()
 	defer master.Unlock()
-	if proc, ok := master.Procs[name]; ok {
-		return master.stop(proc)
+	procs := master.lookup(name)
+	if len(procs) == 0 {
+		return ErrUnknownProcess
 	}
-	return errors.New("Unknown process.")
+	for _, proc := range procs {
+		master.resetCrashState(proc)
+		if err := master.stop(proc); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// DeleteProcess will delete a process and all its files and childs forever.
+// DeleteProcess will delete a process, or every instance of a cluster, and all its files and childs forever.
 func (master *Master) DeleteProcess(name string) error {
 	master.Lock()
 	defer master.Unlock()
 	log.Infof("Trying to delete proc %s", name)
-	if proc, ok := master.Procs[name]; ok {
-		err := master.stop(proc)
-		if err != nil {
-			return err
-		}
-		delete(master.Procs, name)
-		err = master.delete(proc)
-		if err != nil {
+	procs := master.lookup(name)
+	if len(procs) == 0 {
+		return ErrUnknownProcess
+	}
+	for _, proc := range procs {
+		if err := master.deleteProc(proc); err != nil {
 			return err
 		}
-		log.Infof("Successfully deleted proc %s", name)
+		log.Infof("Successfully deleted proc %s", proc.Identifier())
 	}
 	return nil
 }
@@ -253,6 +638,15 @@ func (master *Master) Revive() error {
 			log.Infof("Proc %s does not have KeepAlive set. Will not revive it.", proc.Identifier())
 			continue
 		}
+		// errored procs restarted too often, they only run again after an explicit restart
+		if proc.GetStatus().Status == "errored" {
+			log.Infof("Proc %s is errored. Will not revive it.", proc.Identifier())
+			continue
+		}
+		if proc.GetStatus().Status == "stopped" {
+			log.Infof("Proc %s was stopped. Will not revive it.", proc.Identifier())
+			continue
+		}
 		log.Infof("Reviving proc %s", proc.Identifier())
 		err := master.start(proc)
 		if err != nil {
@@ -264,6 +658,9 @@ func (master *Master) Revive() error {
 
 // NOT thread safe method. Lock should be acquire before calling it.
 func (master *Master) start(proc process.ProcContainer) error {
+	if master.stopping {
+		return ErrStopping
+	}
 	if !proc.IsAlive() {
 		err := proc.Start()
 		if err != nil {
@@ -282,6 +679,8 @@ func (master *Master) delete(proc process.ProcContainer) error {
 }
 
 // NOT thread safe method. Lock should be acquire before calling it.
+// A proc that does not stop within its kill timeout is killed, so a single proc
+// ignoring its stop signal can't hold the lock forever.
 func (master *Master) stop(proc process.ProcContainer) error {
 	if proc.IsAlive() {
 		waitStop := master.Watcher.StopWatcher(proc.Identifier())
@@ -290,7 +689,22 @@ func (master *Master) stop(proc process.ProcContainer) error {
 			return err
 		}
 		if waitStop != nil {
-			<-waitStop
+			var state *os.ProcessState
+			select {
+			case state = <-waitStop:
+			case <-time.After(proc.GetKillTimeout()):
+				log.Warnf("Proc %s did not stop within %s after %s, killing it.",
+					proc.Identifier(), proc.GetKillTimeout(), proc.GetStopSignal())
+				if err := proc.ForceStop(); err != nil {
+					return err
+				}
+				select {
+				case state = <-waitStop:
+				case <-time.After(proc.GetKillTimeout()):
+					return fmt.Errorf("Proc %s did not die after SIGKILL.", proc.Identifier())
+				}
+			}
+			recordExit(proc, state)
 			proc.NotifyStopped()
 			proc.SetStatus("stopped")
 			proc.SetUptime()
@@ -301,10 +715,22 @@ func (master *Master) stop(proc process.ProcContainer) error {
 	return nil
 }
 
-// UpdateStatus will update a process status every 30s.
+// recordExit will record the exit code of proc from its state, if it could be read.
+// NOT thread safe method. Lock should be acquired before calling it.
+func recordExit(proc process.ProcContainer, state *os.ProcessState) {
+	if state != nil {
+		proc.SetExitCode(state.ExitCode())
+	}
+}
+
+// UpdateStatus will update a process status every 30s, until pmgo stops.
 func (master *Master) UpdateStatus() {
 	for {
 		master.Lock()
+		if master.stopping {
+			master.Unlock()
+			return
+		}
 		procs := master.ListProcs()
 		for id := range procs {
 			proc := procs[id]
@@ -318,16 +744,67 @@ func (master *Master) UpdateStatus() {
 func (master *Master) updateStatus(proc process.ProcContainer) {
 	if proc.IsAlive() {
 		proc.SetStatus("running")
-	} else {
-		proc.NotifyStopped()
-		proc.SetStatus("stopped")
+		return
+	}
+	proc.NotifyStopped()
+	// errored or waiting for its restart, keep that status. Errored procs stay so across daemon restarts.
+	if _, pending := master.crashes[proc.Identifier()]; pending || proc.GetStatus().Status == "errored" {
+		return
+	}
+	proc.SetStatus("stopped")
+}
+
+// NOT thread safe method. Lock should be acquire before calling it.
+func (master *Master) reload(proc process.ProcContainer) error {
+	if master.stopping {
+		return ErrStopping
+	}
+	if !proc.IsAlive() {
+		return master.restart(proc, "reload requested")
+	}
+	previous, err := proc.Reload()
+	if err != nil {
+		return err
+	}
+	proc.AddRestart("reload requested")
+	// the watcher is still waiting on the previous instance and reports when it is gone
+	waitStop := master.Watcher.StopWatcher(proc.Identifier())
+	if previous != nil {
+		// Release sets Pid to -1, so it is read first
+		pid := previous.Pid
+		signal, _ := process.ParseSignal(proc.GetStopSignal())
+		log.Infof("Proc %s reloaded, stopping previous instance %d.", proc.Identifier(), pid)
+		if err := process.SignalGroup(pid, signal); err != nil {
+			log.Warnf("Could not stop previous instance of proc %s due to %s.", proc.Identifier(), err)
+		}
+		if waitStop != nil {
+			select {
+			case <-waitStop:
+			case <-time.After(proc.GetKillTimeout()):
+				log.Warnf("Previous instance of proc %s did not stop within %s, killing it.", proc.Identifier(), proc.GetKillTimeout())
+				process.SignalGroup(pid, syscall.SIGKILL)
+				select {
+				case <-waitStop:
+				case <-time.After(proc.GetKillTimeout()):
+					log.Errorf("Previous instance of proc %s did not die after SIGKILL.", proc.Identifier())
+				}
+			}
+		}
+		previous.Release()
 	}
+	master.Watcher.AddProcWatcher(proc)
+	proc.SetStatus("running")
+	proc.SetUptime()
+	return master.saveProcsWrapper()
 }
 
 // NOT thread safe method. Lock should be acquire before calling it.
-func (master *Master) restart(proc process.ProcContainer) error {
+func (master *Master) restart(proc process.ProcContainer, reason string) error {
+	if master.stopping {
+		return ErrStopping
+	}
 	// restat count +1
-	proc.AddRestart()
+	proc.AddRestart(reason)
 	err := master.stop(proc)
 	if err != nil {
 		return err
@@ -348,24 +825,48 @@ func (master *Master) restart(proc process.ProcContainer) error {
 // 	}
 // }
 
-// Stop will stop pmgo and save all of its running procs.
+// Stop will stop pmgo and save all of its running procs. Their status is saved as it was before
+// stopping them, so Revive only starts again the procs that were running. The pending restarts
+// and the source watchers are cancelled first, and the background loops exit, so no proc is
+// started again meanwhile.
 func (master *Master) Stop() error {
+	master.Lock()
+	defer master.Unlock()
 	log.Info("Stopping pmgo...")
+	master.stopping = true
+	for _, state := range master.crashes {
+		if state.timer != nil {
+			state.timer.Stop()
+			state.timer = nil
+		}
+	}
+	for name, state := range master.sources {
+		if state.watcher != nil {
+			state.watcher.Close()
+		}
+		delete(master.sources, name)
+	}
 	procs := master.ListProcs()
 	for id := range procs {
 		proc := procs[id]
-		log.Info("Stopping proc %s", proc.Identifier())
+		status := proc.GetStatus().Status
+		log.Infof("Stopping proc %s", proc.Identifier())
 		master.stop(proc)
+		proc.SetStatus(status)
 	}
 	log.Info("Saving and returning list of procs.")
 	return master.saveProcsWrapper()
 }
 
-// SaveProcs will save a list of procs onto a file inside configPath.
+// SaveProcs will save a list of procs onto a file inside configPath, and onto the dump file
+// Resurrect restores them from.
 // Returns an error in case there's any.
 func (master *Master) SaveProcs() error {
 	master.Lock()
 	defer master.Unlock()
+	if err := master.saveDump(); err != nil {
+		return err
+	}
 	return master.saveProcsWrapper()
 }
 
@@ -379,16 +880,10 @@ func (master *Master) getConfigPath() string {
 	return path.Join(master.SysFolder, "config.toml")
 }
 
-// IsExistProc current proc whether exist
-func (master *Master) IsExistProc(procName string) (bool, error) {
-	if proc, ok := master.Procs[procName]; ok {
-		if !proc.IsAlive() {
-			err := master.RestartProcess(procName)
-			if err != nil {
-				return false, err
-			}
-		}
-		return true, nil
-	}
-	return false, nil
+// IsExistProc will return true if there is a proc, or a cluster, named procName. Dead procs are
+// left as they are, they only run again after an explicit start or restart.
+func (master *Master) IsExistProc(procName string) bool {
+	master.Lock()
+	defer master.Unlock()
+	return len(master.lookup(procName)) > 0
 }
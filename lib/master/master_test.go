@@ -0,0 +1,136 @@
+package master
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/struCoder/pmgo/lib/process"
+	"github.com/struCoder/pmgo/lib/watcher"
+)
+
+// newTestMaster will create a master keeping its files in a temporary folder, removed with the test.
+func newTestMaster(t *testing.T) *Master {
+	dir, err := ioutil.TempDir("", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return &Master{
+		SysFolder: dir + "/",
+		Watcher:   watcher.InitWatcher(),
+		Procs:     make(map[string]process.ProcContainer),
+		crashes:   make(map[string]*crashState),
+		health:    make(map[string]*healthState),
+		memory:    make(map[string]*memoryState),
+		cron:      make(map[string]*cronState),
+		sources:   make(map[string]*sourceState),
+		startTime: time.Now(),
+	}
+}
+
+// newTestProc will register on master a stopped proc named name, running script with sh.
+// Returns the proc.
+func newTestProc(t *testing.T, master *Master, name string, script string) *process.Proc {
+	dir := filepath.Join(master.SysFolder, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	proc := &process.Proc{
+		Name:        name,
+		Cmd:         "/bin/sh",
+		Args:        []string{"-c", script},
+		Runtime:     process.RuntimeExec,
+		Path:        dir,
+		Cwd:         dir,
+		Pidfile:     filepath.Join(dir, name+".pid"),
+		Outfile:     filepath.Join(dir, name+".out"),
+		Errfile:     filepath.Join(dir, name+".err"),
+		KillTimeout: time.Second,
+		Status:      &process.ProcStatus{Status: "stopped"},
+	}
+	master.Procs[name] = proc
+	return proc
+}
+
+// waitFor will poll condition until it is true, failing the test after timeout.
+func waitFor(t *testing.T, timeout time.Duration, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopCancelsRestarts(t *testing.T) {
+	master := newTestMaster(t)
+	go master.WatchProcs()
+	proc := newTestProc(t, master, "app", "exit 1")
+	proc.KeepAlive = true
+	proc.RestartPolicy = process.RestartPolicy{Delay: 200 * time.Millisecond, MaxDelay: 200 * time.Millisecond}
+	master.Lock()
+	err := master.start(proc)
+	master.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "a pending restart", func() bool {
+		master.Lock()
+		defer master.Unlock()
+		return proc.GetStatus().Status == "waiting restart"
+	})
+	restarts := proc.GetStatus().Restarts
+
+	loops := make(chan string, 2)
+	go func() { master.HealthChecks(); loops <- "health" }()
+	go func() { master.CronRestarts(); loops <- "cron" }()
+	if err := master.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-loops:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the background loops did not exit after Stop")
+		}
+	}
+	time.Sleep(500 * time.Millisecond)
+	master.Lock()
+	defer master.Unlock()
+	if proc.GetStatus().Restarts != restarts {
+		t.Fatal("the proc was restarted while pmgo was stopping")
+	}
+	if err := master.start(proc); err != ErrStopping {
+		t.Fatalf("got error %v starting a proc after Stop, want %v", err, ErrStopping)
+	}
+}
+
+func TestStopEscalatesToKill(t *testing.T) {
+	master := newTestMaster(t)
+	proc := newTestProc(t, master, "app", "trap '' TERM; while true; do sleep 0.1; done")
+	proc.KillTimeout = 300 * time.Millisecond
+	if err := master.start(proc); err != nil {
+		t.Fatal(err)
+	}
+	// give sh the time to ignore SIGTERM
+	time.Sleep(200 * time.Millisecond)
+
+	begin := time.Now()
+	if err := master.stop(proc); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(begin); took < proc.KillTimeout {
+		t.Fatalf("stopped after %s, before the kill timeout", took)
+	}
+	if proc.IsAlive() {
+		t.Fatal("proc ignoring its stop signal is alive after stop")
+	}
+	if status := proc.GetStatus().Status; status != "stopped" {
+		t.Fatalf("got status %q, want stopped", status)
+	}
+}
@@ -0,0 +1,84 @@
+package master
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pmgo/lib/utils"
+)
+
+const (
+	// memorySampleInterval is the time between two samples of the procs memory.
+	memorySampleInterval = 10 * time.Second
+	// defaultMemorySamples is the number of samples in a row above MaxMemory before a restart.
+	defaultMemorySamples = 3
+)
+
+// memoryState keeps track of the memory samples of a proc run.
+type memoryState struct {
+	pid  int // pid is the proc run being sampled.
+	over int // over is the number of samples in a row above the limit.
+}
+
+// MemoryChecks will loop, until pmgo stops, sampling the memory of the running procs that have a MaxMemory,
+// and gracefully restart the ones staying above it.
+func (master *Master) MemoryChecks() {
+	for {
+		time.Sleep(memorySampleInterval)
+		master.Lock()
+		if master.stopping {
+			master.Unlock()
+			return
+		}
+		for name := range master.memory {
+			if _, ok := master.Procs[name]; !ok {
+				delete(master.memory, name)
+			}
+		}
+		for _, proc := range master.ListProcs() {
+			maxMemory, samples := proc.GetMaxMemory()
+			if maxMemory <= 0 || !proc.IsAlive() {
+				continue
+			}
+			if samples <= 0 {
+				samples = defaultMemorySamples
+			}
+			state, ok := master.memory[proc.Identifier()]
+			if !ok || state.pid != proc.GetPid() {
+				state = &memoryState{pid: proc.GetPid()}
+				master.memory[proc.Identifier()] = state
+			}
+			status := proc.GetStatus()
+			if status.Sys == nil {
+				continue
+			}
+			rss := int64(status.Sys.Memory)
+			if rss <= maxMemory {
+				state.over = 0
+				continue
+			}
+			state.over++
+			log.Warnf("Proc %s uses %s, above its %s limit (%d/%d).", proc.Identifier(),
+				utils.FormatMemory(int(rss)), utils.FormatMemory(int(maxMemory)), state.over, samples)
+			if state.over < samples {
+				continue
+			}
+			reason := fmt.Sprintf("memory %s above %s for %d samples",
+				utils.FormatMemory(int(rss)), utils.FormatMemory(int(maxMemory)), state.over)
+			log.Errorf("Proc %s restarted: %s.", proc.Identifier(), reason)
+			delete(master.memory, proc.Identifier())
+			if err := master.restart(proc, reason); err != nil {
+				log.Warnf("Could not restart process %s due to %s.", proc.Identifier(), err)
+			}
+		}
+		master.Unlock()
+	}
+}
+
+func formatMaxMemory(maxMemory int64, samples int) string {
+	if samples <= 0 {
+		samples = defaultMemorySamples
+	}
+	return fmt.Sprintf("%s for %d samples of %s", utils.FormatMemory(int(maxMemory)), samples, memorySampleInterval)
+}
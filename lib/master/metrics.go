@@ -0,0 +1,120 @@
+package master
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pidusage"
+)
+
+// metric is a Prometheus metric family with one sample per proc.
+type metric struct {
+	name    string
+	kind    string
+	help    string
+	samples []string
+}
+
+func (m *metric) add(labels string, value float64) {
+	m.samples = append(m.samples, fmt.Sprintf("%s%s %g", m.name, labels, value))
+}
+
+func (m *metric) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	for _, sample := range m.samples {
+		fmt.Fprintln(w, sample)
+	}
+}
+
+// WriteMetrics will write the procs and daemon metrics on w, in the Prometheus text format.
+func (master *Master) WriteMetrics(w io.Writer) {
+	up := &metric{name: "pmgo_process_up", kind: "gauge", help: "Whether the process is running."}
+	cpu := &metric{name: "pmgo_process_cpu_percent", kind: "gauge", help: "Process CPU usage percent."}
+	rss := &metric{name: "pmgo_process_rss_bytes", kind: "gauge", help: "Process resident memory in bytes."}
+	uptime := &metric{name: "pmgo_process_uptime_seconds", kind: "gauge", help: "Seconds since the process was started, 0 when it is not running."}
+	restarts := &metric{name: "pmgo_process_restarts_total", kind: "counter", help: "Number of times the process was restarted."}
+	exitCode := &metric{name: "pmgo_process_last_exit_code", kind: "gauge", help: "Exit code of the last run of the process, -1 when killed by a signal."}
+
+	master.Lock()
+	procs := master.ListProcs()
+	now := time.Now().Unix()
+	for _, proc := range procs {
+		labels := fmt.Sprintf("{name=\"%s\"}", escapeLabel(proc.Identifier()))
+		status := proc.GetStatus()
+		alive := proc.IsAlive()
+		up.add(labels, boolValue(alive))
+		if alive && status.Sys != nil {
+			cpu.add(labels, status.Sys.CPU)
+			rss.add(labels, status.Sys.Memory)
+		} else {
+			cpu.add(labels, 0)
+			rss.add(labels, 0)
+		}
+		if alive {
+			uptime.add(labels, float64(now-status.StartTime))
+		} else {
+			uptime.add(labels, 0)
+		}
+		restarts.add(labels, float64(status.Restarts))
+		exitCode.add(labels, float64(status.ExitCode))
+	}
+	master.Unlock()
+
+	for _, m := range []*metric{up, cpu, rss, uptime, restarts, exitCode} {
+		m.write(w)
+	}
+
+	processes := &metric{name: "pmgo_daemon_processes", kind: "gauge", help: "Number of processes managed by the daemon."}
+	processes.add("", float64(len(procs)))
+	daemonUptime := &metric{name: "pmgo_daemon_uptime_seconds", kind: "gauge", help: "Seconds since the daemon was started."}
+	daemonUptime.add("", time.Since(master.startTime).Seconds())
+	goroutines := &metric{name: "pmgo_daemon_goroutines", kind: "gauge", help: "Number of goroutines of the daemon."}
+	goroutines.add("", float64(runtime.NumGoroutine()))
+	daemonCPU := &metric{name: "pmgo_daemon_cpu_percent", kind: "gauge", help: "Daemon CPU usage percent."}
+	daemonRSS := &metric{name: "pmgo_daemon_rss_bytes", kind: "gauge", help: "Daemon resident memory in bytes."}
+	if sys, err := pidusage.GetStat(os.Getpid()); err == nil {
+		daemonCPU.add("", sys.CPU)
+		daemonRSS.add("", sys.Memory)
+	}
+	for _, m := range []*metric{processes, daemonUptime, goroutines, daemonCPU, daemonRSS} {
+		m.write(w)
+	}
+}
+
+// ServeMetrics will serve the metrics on addr/metrics, using TLS when tlsConfig is not nil.
+// It only returns when the server fails.
+// Returns an error in case there's any.
+func (master *Master) ServeMetrics(addr string, tlsConfig *tls.Config) error {
+	log.Infof("Serving metrics on %s", addr)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		master.WriteMetrics(&body)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(body.Bytes())
+	})
+	server := &http.Server{Addr: addr, Handler: master.authorize(mux), TLSConfig: tlsConfig}
+	if tlsConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,327 @@
+package master
+
+// openAPIDocument is the OpenAPI 3 description of the REST API served by HTTPApi.
+// Durations are given in nanoseconds, as encoding/json does for time.Duration.
+const openAPIDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "pmgo",
+    "description": "REST API of the pmgo daemon.",
+    "version": "1.0.0"
+  },
+  "paths": {
+    "/api/procs": {
+      "get": {
+        "summary": "List every proc status.",
+        "responses": {
+          "200": {"description": "The procs.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ProcResponse"}}}}
+        }
+      },
+      "post": {
+        "summary": "Build and start a Go application.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GoBin"}}}},
+        "responses": {
+          "201": {"description": "The started proc info.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ProcInfo"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/procs/{name}": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "get": {
+        "summary": "Get a proc, or cluster, detail info.",
+        "responses": {
+          "200": {"description": "The proc info.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ProcInfo"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Stop and delete a proc, or every instance of a cluster.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Ack"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/procs/{name}/{action}": {
+      "parameters": [
+        {"$ref": "#/components/parameters/Name"},
+        {"name": "action", "in": "path", "required": true, "schema": {"type": "string", "enum": ["start", "stop", "restart", "reload", "rebuild", "flush"]}}
+      ],
+      "post": {
+        "summary": "Start, stop, restart, reload, rebuild from source or flush the logs of a proc, or every instance of a cluster.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Ack"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/procs/{name}/instances": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "put": {
+        "summary": "Scale a cluster to a number of instances.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"type": "object", "properties": {"Instances": {"type": "integer"}}}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Ack"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/procs/{name}/history": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "get": {
+        "summary": "List the builds kept of a proc, or cluster, newest first.",
+        "responses": {
+          "200": {"description": "The builds.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/HistoryResponse"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/procs/{name}/rollback": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Switch a proc, or cluster, back to a previous build and restart it.",
+        "requestBody": {"required": false, "content": {"application/json": {"schema": {"type": "object", "properties": {"Version": {"type": "string", "description": "Defaults to the build before the active one."}}}}}},
+        "responses": {
+          "200": {"description": "The build switched to.", "content": {"application/json": {"schema": {"type": "object", "properties": {"version": {"type": "string"}}}}}},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/logs": {
+      "post": {
+        "summary": "Read the last lines of the procs logs, or the ones written after the given offsets.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LogsRequest"}}}},
+        "responses": {
+          "200": {"description": "The log lines.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LogsResponse"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/save": {
+      "post": {
+        "summary": "Save the list of procs onto the config file and the dump file resurrect restores.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Ack"}
+        }
+      }
+    },
+    "/api/resurrect": {
+      "post": {
+        "summary": "Restore the saved procs, starting or stopping them as they were when saved, and stop the running procs that were not saved.",
+        "responses": {
+          "200": {"description": "The result of every saved, or stopped, proc.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ResurrectResponse"}}}},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/openapi.json": {
+      "get": {
+        "summary": "This document.",
+        "responses": {
+          "200": {"description": "The OpenAPI document.", "content": {"application/json": {}}}
+        }
+      }
+    }
+  },
+  "security": [{"token": []}, {}],
+  "components": {
+    "securitySchemes": {
+      "token": {"type": "http", "scheme": "bearer", "description": "Required when the daemon has a TokenFile."}
+    },
+    "parameters": {
+      "Name": {"name": "name", "in": "path", "required": true, "description": "The proc or cluster name.", "schema": {"type": "string"}}
+    },
+    "responses": {
+      "Ack": {"description": "The action succeeded.", "content": {"application/json": {"schema": {"type": "object", "properties": {"ack": {"type": "boolean"}}}}}},
+      "Error": {"description": "The action failed.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "properties": {
+          "error": {"type": "string"},
+          "output": {"type": "string", "description": "The build output, on build failures."}
+        }
+      },
+      "GoBin": {
+        "type": "object",
+        "required": ["SourcePath", "Name"],
+        "properties": {
+          "SourcePath": {"type": "string"},
+          "Name": {"type": "string"},
+          "KeepAlive": {"type": "boolean"},
+          "Runtime": {"type": "string", "enum": ["go", "exec", "interpreter"]},
+          "Interpreter": {"type": "string"},
+          "Copy": {"type": "boolean"},
+          "Args": {"type": "array", "items": {"type": "string"}},
+          "Env": {"type": "object", "additionalProperties": {"type": "string"}},
+          "EnvFiles": {"type": "array", "items": {"type": "string"}},
+          "CleanEnv": {"type": "boolean"},
+          "Cwd": {"type": "string"},
+          "LogRotate": {
+            "type": "object",
+            "properties": {
+              "MaxSize": {"type": "integer"},
+              "Interval": {"type": "integer"},
+              "Keep": {"type": "integer"},
+              "Compress": {"type": "boolean"}
+            }
+          },
+          "RestartPolicy": {
+            "type": "object",
+            "properties": {
+              "Delay": {"type": "integer"},
+              "MaxDelay": {"type": "integer"},
+              "Jitter": {"type": "number", "description": "Defaults to 0.1 when left out, 0 disables the jitter."},
+              "MinUptime": {"type": "integer"},
+              "MaxRestarts": {"type": "integer", "description": "Defaults to 15 when left out, 0 allows no restart and -1 is unlimited."},
+              "Window": {"type": "integer"}
+            }
+          },
+          "StopSignal": {"type": "string"},
+          "KillTimeout": {"type": "integer"},
+          "Sockets": {"type": "array", "items": {"type": "string"}},
+          "Instances": {"type": "integer"},
+          "MaxMemory": {"type": "integer"},
+          "MaxMemorySamples": {"type": "integer"},
+          "CronRestart": {"type": "string"},
+          "User": {"type": "string"},
+          "Group": {"type": "string"},
+          "Groups": {"type": "array", "items": {"type": "string"}},
+          "Cgroup": {
+            "type": "object",
+            "properties": {
+              "CPUMax": {"type": "string", "description": "CPUs, such as 0.5 or 150%, or a raw cpu.max."},
+              "MemoryMax": {"type": "integer"},
+              "PidsMax": {"type": "integer"},
+              "IOWeight": {"type": "integer"}
+            }
+          },
+          "Build": {
+            "type": "object",
+            "properties": {
+              "Package": {"type": "string", "description": "Package built, relative to SourcePath, such as ./cmd/server."},
+              "Tags": {"type": "array", "items": {"type": "string"}},
+              "Ldflags": {"type": "string"},
+              "Gcflags": {"type": "string"},
+              "Trimpath": {"type": "boolean"},
+              "Race": {"type": "boolean"},
+              "Env": {"type": "object", "additionalProperties": {"type": "string"}},
+              "Toolchain": {"type": "string"}
+            }
+          },
+          "KeepBuilds": {"type": "integer", "description": "Builds kept for rollbacks, defaults to the daemon KeepBuilds."},
+          "SourceWatch": {"type": "object", "properties": {"Enabled": {"type": "boolean"}, "Ignore": {"type": "array", "items": {"type": "string"}}, "Debounce": {"type": "integer"}}},
+          "HealthCheck": {
+            "type": "object",
+            "properties": {
+              "HTTP": {"type": "string"},
+              "TCP": {"type": "string"},
+              "Exec": {"type": "string"},
+              "Interval": {"type": "integer"},
+              "Timeout": {"type": "integer"},
+              "Failures": {"type": "integer"}
+            }
+          }
+        }
+      },
+      "ProcInfo": {
+        "type": "object",
+        "additionalProperties": {"type": "string"}
+      },
+      "HistoryResponse": {
+        "type": "object",
+        "properties": {
+          "Name": {"type": "string"},
+          "Active": {"type": "string"},
+          "Builds": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "Version": {"type": "string"},
+                "BuiltAt": {"type": "string", "format": "date-time"},
+                "Commit": {"type": "string"},
+                "SourceHash": {"type": "string"},
+                "Build": {"type": "string"}
+              }
+            }
+          }
+        }
+      },
+      "ResurrectResponse": {
+        "type": "object",
+        "properties": {
+          "Procs": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "Name": {"type": "string"},
+                "Result": {"type": "string", "enum": ["restored", "restored stopped", "already exists", "started", "stopped", "stopped, not saved", ""]},
+                "Error": {"type": "string"}
+              }
+            }
+          }
+        }
+      },
+      "ProcResponse": {
+        "type": "object",
+        "properties": {
+          "Procs": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "Name": {"type": "string"},
+                "Pid": {"type": "integer"},
+                "Status": {
+                  "type": "object",
+                  "properties": {
+                    "Status": {"type": "string"},
+                    "Restarts": {"type": "integer"},
+                    "StartTime": {"type": "integer"},
+                    "Uptime": {"type": "string"},
+                    "Sys": {"type": "object", "properties": {"CPU": {"type": "number"}, "Memory": {"type": "number"}}},
+                    "ExitCode": {"type": "integer"},
+                    "Health": {"type": "object", "properties": {"Status": {"type": "string"}, "Failures": {"type": "integer"}, "Output": {"type": "string"}, "CheckedAt": {"type": "integer"}}},
+                    "History": {"type": "array", "items": {"type": "object", "properties": {"Time": {"type": "integer"}, "Reason": {"type": "string"}}}}
+                  }
+                }
+              }
+            }
+          }
+        }
+      },
+      "LogsRequest": {
+        "type": "object",
+        "properties": {
+          "Names": {"type": "array", "items": {"type": "string"}},
+          "Lines": {"type": "integer"},
+          "ErrOnly": {"type": "boolean"},
+          "Offsets": {"type": "object", "additionalProperties": {"type": "integer"}},
+          "Inodes": {"type": "object", "additionalProperties": {"type": "integer"}}
+        }
+      },
+      "LogsResponse": {
+        "type": "object",
+        "properties": {
+          "Lines": {
+            "type": "array",
+            "items": {"type": "object", "properties": {"Name": {"type": "string"}, "Err": {"type": "boolean"}, "Text": {"type": "string"}}}
+          },
+          "Offsets": {"type": "object", "additionalProperties": {"type": "integer"}},
+          "Inodes": {"type": "object", "additionalProperties": {"type": "integer"}}
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package master
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// peerUID will return the uid of the process on the other end of the unix socket conn, using SO_PEERCRED.
+// Returns a tuple with the uid and an error in case there's any.
+func peerUID(conn net.Conn) (int, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return -1, errors.New("not a unix socket connection")
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return -1, err
+	}
+	if credErr != nil {
+		return -1, credErr
+	}
+	return int(cred.Uid), nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package master
+
+import (
+	"errors"
+	"net"
+)
+
+// peerUID is only supported on linux, so connections are rejected when AllowedUIDs is set elsewhere.
+func peerUID(conn net.Conn) (int, error) {
+	return -1, errors.New("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,71 @@
+package master
+
+import (
+	"fmt"
+
+	"github.com/struCoder/pmgo/lib/preparable"
+	"github.com/struCoder/pmgo/lib/process"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RebuildProcess will build the proc, or cluster, named name again from its saved source and build
+// options, then restart it with the new binary if it is running. When the build fails, the running
+// binary is kept and a BuildError is returned.
+func (master *Master) RebuildProcess(name string) error {
+	return master.rebuildProcess(name, "rebuild")
+}
+
+// rebuildProcess will rebuild the proc, or cluster, named name and restart it recording reason on
+// its history. The build runs without holding the lock, so the daemon keeps answering meanwhile.
+func (master *Master) rebuildProcess(name string, reason string) error {
+	master.Lock()
+	procs := master.lookup(name)
+	if len(procs) == 0 {
+		master.Unlock()
+		return ErrUnknownProcess
+	}
+	// instances share the binary of their cluster
+	name = groupName(procs[0])
+	procPreparable, err := master.recipe(name, procs[0])
+	if err != nil {
+		master.Unlock()
+		return err
+	}
+	running := false
+	for _, proc := range master.lookup(name) {
+		running = running || proc.IsAlive()
+	}
+	master.Unlock()
+
+	log.Infof("Rebuilding proc %s", name)
+	output, err := procPreparable.PrepareBin()
+	if err != nil {
+		return &BuildError{Err: err, Output: output}
+	}
+	if !running {
+		log.Infof("Proc %s rebuilt. It is not running, so it was not restarted.", name)
+		return nil
+	}
+	return master.restartProcess(name, reason)
+}
+
+// recipe will return the preparable building the binary of proc, on the group name, the same way
+// it was built when started.
+// Returns a tuple with the preparable and an error in case there's any.
+func (master *Master) recipe(name string, proc process.ProcContainer) (*preparable.Preparable, error) {
+	if proc.GetSourcePath() == "" {
+		return nil, fmt.Errorf("proc %s has no source to build it from", name)
+	}
+	runtime, interpreter := proc.GetRuntime()
+	return &preparable.Preparable{
+		Name:        name,
+		SourcePath:  proc.GetSourcePath(),
+		SysFolder:   master.SysFolder,
+		Language:    runtime,
+		Interpreter: interpreter,
+		Copy:        proc.GetCopy(),
+		Build:       proc.GetBuild(),
+		KeepBuilds:  proc.GetKeepBuilds(),
+	}, nil
+}
@@ -1,13 +1,18 @@
 package master
 
 import (
-	"fmt"
+	"crypto/tls"
 	"net"
 	"net/rpc"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pmgo/lib/build"
+	"github.com/struCoder/pmgo/lib/cgroup"
+	"github.com/struCoder/pmgo/lib/health"
+	"github.com/struCoder/pmgo/lib/logrotate"
 	"github.com/struCoder/pmgo/lib/process"
+	"github.com/struCoder/pmgo/lib/sourcewatch"
 )
 
 // RemoteMaster is a struct that holds the master instance.
@@ -22,10 +27,47 @@ type RemoteClient struct {
 
 // GoBin is a struct that represents the necessary arguments for a go binary to be built.
 type GoBin struct {
-	SourcePath string   // SourcePath is the package path. (Ex: github.com/topfreegames/pmgo)
+	SourcePath string   // SourcePath is the package path. (Ex: github.com/topfreegames/pmgo) The binary or script on the exec and interpreter runtimes.
 	Name       string   // Name is the process name that will be given to the process.
 	KeepAlive  bool     // KeepAlive will determine whether pmgo should keep the proc live or not.
 	Args       []string // Args is an array containing all the extra args that will be passed to the binary after compilation.
+
+	Runtime     string // Runtime is how the process is started: go, exec or interpreter. Defaults to go.
+	Interpreter string // Interpreter runs the script on the interpreter runtime, such as python3. Defaults from the script extension.
+	Copy        bool   // Copy copies the binary into the proc folder on the exec runtime, instead of running it in place.
+
+	Env      map[string]string // Env is a map with extra environment variables that will be given to the process.
+	EnvFiles []string          // EnvFiles are .env files read on every start. Env takes precedence over them.
+	CleanEnv bool              // CleanEnv will start the process without inheriting the daemon environment.
+	Cwd      string            // Cwd is the working directory the process will be started on. Defaults to SourcePath.
+
+	LogRotate     *logrotate.Config      // LogRotate is the out and err files rotation. Defaults to the daemon one when nil.
+	RestartPolicy *process.RestartPolicy // RestartPolicy is how the process is restarted when it dies. Defaults to the daemon one when nil.
+	StopSignal    string                 // StopSignal is the signal asking the process to stop. Defaults to SIGTERM.
+	KillTimeout   time.Duration          // KillTimeout is how long the process may take to stop before it is killed.
+	Sockets       []string               // Sockets are listened by pmgo and handed to the process, as [name=]tcp://host:port or [name=]unix:///path.
+	Instances     int                    // Instances is the number of processes started on cluster mode, -1 for one per CPU. 0 disables it.
+	HealthCheck   health.Check           // HealthCheck probes the process while it runs. Disabled when empty.
+
+	MaxMemory        int64 // MaxMemory is the RSS in bytes above which the process is restarted. 0 disables it.
+	MaxMemorySamples int   // MaxMemorySamples is the number of samples in a row above MaxMemory before a restart. Defaults to 3.
+
+	CronRestart string             // CronRestart is a cron expression the process is restarted on, such as "0 4 * * *".
+	SourceWatch sourcewatch.Config // SourceWatch rebuilds and restarts the process when its source changes.
+	Build       build.Spec         // Build is how the source is compiled on the go runtime. Defaults to go build on SourcePath.
+	KeepBuilds  int                // KeepBuilds is the number of builds kept for rollbacks. Defaults to the daemon one.
+
+	User   string   // User is the user, name or uid, the process runs as. Requires a root daemon.
+	Group  string   // Group is the group, name or gid, the process runs as. Defaults to the User primary group.
+	Groups []string // Groups are the supplementary groups of the process. Defaults to the User groups.
+
+	Cgroup cgroup.Limits // Cgroup are the hard resource limits enforced with cgroup v2. Unlimited when empty.
+}
+
+// Scale is a struct that represents the number of instances a cluster should have.
+type Scale struct {
+	Name      string // Name is the cluster name.
+	Instances int    // Instances is the new number of instances.
 }
 
 // ProcDataResponse is a struct than about proc attr
@@ -49,22 +91,31 @@ func (remote_master *RemoteMaster) Save(req string, ack *bool) error {
 	return remote_master.master.SaveProcs()
 }
 
+// Resurrect will make the procs on pmgo match the ones saved by Save.
+// It returns an error and binds the result of every saved proc to response.
+func (remote_master *RemoteMaster) Resurrect(req string, response *ResurrectResponse) error {
+	resurrected, err := remote_master.master.Resurrect()
+	if err != nil {
+		return err
+	}
+	*response = *resurrected
+	return nil
+}
+
 // StartGoBin will build a binary based on the arguments passed on goBin, then it will start the process
 // and keep it alive if KeepAlive is set to true.
 // It returns an error and binds true to ack pointer.
 func (remote_master *RemoteMaster) StartGoBin(goBin *GoBin, ack *bool) error {
-	isExist, err := remote_master.master.IsExistProc(goBin.Name)
-	if err != nil {
-		return err
+	if remote_master.master.IsExistProc(goBin.Name) {
+		return ErrProcessExists
 	}
-	// if current proc is exist just return
-	if isExist {
-		return nil
-	}
-	preparable, output, err := remote_master.master.Prepare(goBin.SourcePath, goBin.Name, "go", goBin.KeepAlive, goBin.Args)
+	preparable, output, err := remote_master.master.Prepare(goBin, "go")
 	*ack = true
+	if _, invalid := err.(*ValidationError); invalid {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("ERROR: %s OUTPUT: %s", err, string(output))
+		return &BuildError{Err: err, Output: output}
 	}
 	return remote_master.master.RunPreparable(preparable)
 }
@@ -76,6 +127,40 @@ func (remote_master *RemoteMaster) RestartProcess(procName string, ack *bool) er
 	return remote_master.master.RestartProcess(procName)
 }
 
+// ReloadProcess will start a new instance of a process before stopping the current one.
+// It returns an error in case there's any.
+func (remote_master *RemoteMaster) ReloadProcess(procName string, ack *bool) error {
+	*ack = true
+	return remote_master.master.ReloadProcess(procName)
+}
+
+// RebuildProcess will build a process again from its saved source and build options, restarting
+// it when the build succeeds.
+// It returns an error in case there's any.
+func (remote_master *RemoteMaster) RebuildProcess(procName string, ack *bool) error {
+	*ack = true
+	return remote_master.master.RebuildProcess(procName)
+}
+
+// History will list the builds kept of a process.
+// It returns an error and binds the builds to response.
+func (remote_master *RemoteMaster) History(procName string, response *HistoryResponse) error {
+	history, err := remote_master.master.History(procName)
+	if err != nil {
+		return err
+	}
+	*response = *history
+	return nil
+}
+
+// RollbackProcess will switch a process back to a previous build and restart it.
+// It returns an error and binds the version switched to to version.
+func (remote_master *RemoteMaster) RollbackProcess(rollback *Rollback, version *string) error {
+	rolledBack, err := remote_master.master.RollbackProcess(rollback)
+	*version = rolledBack
+	return err
+}
+
 // StartProcess will start a process that was previously built using GoBin.
 // It returns an error in case there's any.
 func (remote_master *RemoteMaster) StartProcess(procName string, ack *bool) error {
@@ -90,10 +175,38 @@ func (remote_master *RemoteMaster) StopProcess(procName string, ack *bool) error
 	return remote_master.master.StopProcess(procName)
 }
 
+// ScaleProcess will add or remove instances of a cluster.
+// It returns an error in case there's any.
+func (remote_master *RemoteMaster) ScaleProcess(scale *Scale, ack *bool) error {
+	*ack = true
+	return remote_master.master.ScaleProcess(scale.Name, scale.Instances)
+}
+
+// FlushProcess will truncate the out and err files of a process.
+// It returns an error in case there's any.
+func (remote_master *RemoteMaster) FlushProcess(procName string, ack *bool) error {
+	*ack = true
+	return remote_master.master.FlushProcess(procName)
+}
+
+// Logs will read the new lines of the procs out and err files. Clients follow the files by
+// calling it again with the offsets of the previous response.
+// It returns an error in case there's any.
+func (remote_master *RemoteMaster) Logs(request *LogsRequest, response *LogsResponse) error {
+	logs, err := remote_master.master.Logs(request)
+	if err != nil {
+		return err
+	}
+	*response = *logs
+	return nil
+}
+
 // MonitStatus will query for the status of each process and bind it to procs pointer list.
 // It returns an error in case there's any.
 func (remote_master *RemoteMaster) MonitStatus(req string, response *ProcResponse) error {
 	req = ""
+	remote_master.master.Lock()
+	defer remote_master.master.Unlock()
 	procs := remote_master.master.ListProcs()
 	procsResponse := []*ProcDataResponse{}
 	if len(procs) >= 1 {
@@ -102,7 +215,8 @@ func (remote_master *RemoteMaster) MonitStatus(req string, response *ProcRespons
 			procData := &ProcDataResponse{
 				Name:   proc.Identifier(),
 				Pid:    proc.GetPid(),
-				Status: proc.GetStatus(),
+				// copied, since the response is encoded once the lock is released
+				Status: proc.GetStatus().Copy(),
 				// KeepAlive: proc.ShouldKeepAlive(),
 			}
 			procsResponse = append(procsResponse, procData)
@@ -134,30 +248,82 @@ func (remote_master *RemoteMaster) GetProcByName(procName string, response *map[
 	return nil
 }
 
-// StartRemoteMasterServer starts a remote pmgo server listening on dsn address and binding to
-// configFile.
+// StartRemoteMasterServer starts a remote pmgo server listening on the unix socket next to configFile
+// and, when it is set, on the TCPAddr of configFile.
 // It returns a RemoteMaster instance.
-func StartRemoteMasterServer(dsn string, configFile string) *RemoteMaster {
+func StartRemoteMasterServer(configFile string) *RemoteMaster {
 	remoteMaster := &RemoteMaster{
 		master: InitMaster(configFile),
 	}
+	dsn := remoteMaster.master.TCPAddr
 	rpc.Register(remoteMaster)
-	l, e := net.Listen("tcp", dsn)
+	unixListener, e := listenUnix(SocketFile(configFile))
 	if e != nil {
 		log.Fatal("listen error: ", e)
 	}
-	go rpc.Accept(l)
+	go acceptUnix(unixListener, remoteMaster.master.AllowedUIDs)
+	tlsConfig, e := remoteMaster.master.serverTLSConfig()
+	if e != nil {
+		log.Fatal("tls error: ", e)
+	}
+	if dsn != "" || remoteMaster.master.HTTPAddr != "" || remoteMaster.master.MetricsAddr != "" {
+		if e := remoteMaster.master.checkAuthConfig(); e != nil {
+			log.Fatal("auth error: ", e)
+		}
+	}
+	if dsn != "" {
+		if e := remoteMaster.master.checkExposed(dsn); e != nil {
+			log.Fatal("auth error: ", e)
+		}
+		l, e := net.Listen("tcp", dsn)
+		if e != nil {
+			log.Fatal("listen error: ", e)
+		}
+		if tlsConfig != nil {
+			l = tls.NewListener(l, tlsConfig)
+		}
+		go remoteMaster.master.acceptTCP(l)
+	}
+	if addr := remoteMaster.master.HTTPAddr; addr != "" {
+		go func() {
+			log.Errorf("REST API stopped: %s", NewHTTPApi(remoteMaster).ListenAndServe(addr, tlsConfig))
+		}()
+	}
+	if addr := remoteMaster.master.MetricsAddr; addr != "" {
+		go func() {
+			log.Errorf("Metrics endpoint stopped: %s", remoteMaster.master.ServeMetrics(addr, tlsConfig))
+		}()
+	}
 	return remoteMaster
 }
 
 // StartRemoteClient will start a remote client that can talk to a remote server that
-// is already running on dsn address.
+// is already running on dsn address, either a TCP address or a unix:// socket.
+// TCP connections are authenticated with auth, which may be nil for daemons without TLS nor tokens.
 // It returns an error in case there's any or it could not connect within the timeout.
-func StartRemoteClient(dsn string, timeout time.Duration) (*RemoteClient, error) {
-	conn, err := net.DialTimeout("tcp", dsn, timeout)
+func StartRemoteClient(dsn string, timeout time.Duration, auth *ClientAuth) (*RemoteClient, error) {
+	network, address := splitDsn(dsn)
+	conn, err := net.DialTimeout(network, address, timeout)
 	if err != nil {
 		return nil, err
 	}
+	if network == "tcp" {
+		if auth == nil {
+			auth = &ClientAuth{}
+		}
+		if auth.useTLS() {
+			config, err := auth.tlsConfig(address)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			conn = tls.Client(conn, config)
+		}
+		if err := auth.authenticate(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
 	return &RemoteClient{conn: rpc.NewClient(conn)}, nil
 }
 
@@ -168,18 +334,24 @@ func (client *RemoteClient) Save() error {
 	return client.conn.Call("RemoteMaster.Save", "", &started)
 }
 
+// Resurrect is a wrapper that calls the remote Resurrect.
+// It returns a tuple with the result of every saved proc and an error in case there's any.
+func (client *RemoteClient) Resurrect() (*ResurrectResponse, error) {
+	response := &ResurrectResponse{}
+	err := client.conn.Call("RemoteMaster.Resurrect", "", response)
+	return response, err
+}
+
 // StartGoBin is a wrapper that calls the remote StartsGoBin.
 // It returns an error in case there's any.
-func (client *RemoteClient) StartGoBin(sourcePath string, name string, keepAlive bool, args []string) error {
+func (client *RemoteClient) StartGoBin(goBin *GoBin) error {
 	var started bool
-	goBin := &GoBin{
-		SourcePath: sourcePath,
-		Name:       name,
-		KeepAlive:  keepAlive,
-		Args:       args,
+	err := client.conn.Call("RemoteMaster.StartGoBin", goBin, &started)
+	// errors reach the client as plain text
+	if err != nil && err.Error() == ErrProcessExists.Error() {
+		return ErrProcessExists
 	}
-
-	return client.conn.Call("RemoteMaster.StartGoBin", goBin, &started)
+	return err
 }
 
 // RestartProcess is a wrapper that calls the remote RestartProcess.
@@ -189,6 +361,36 @@ func (client *RemoteClient) RestartProcess(procName string) error {
 	return client.conn.Call("RemoteMaster.RestartProcess", procName, &started)
 }
 
+// ReloadProcess is a wrapper that calls the remote ReloadProcess.
+// It returns an error in case there's any.
+func (client *RemoteClient) ReloadProcess(procName string) error {
+	var reloaded bool
+	return client.conn.Call("RemoteMaster.ReloadProcess", procName, &reloaded)
+}
+
+// RebuildProcess is a wrapper that calls the remote RebuildProcess.
+// It returns an error in case there's any.
+func (client *RemoteClient) RebuildProcess(procName string) error {
+	var rebuilt bool
+	return client.conn.Call("RemoteMaster.RebuildProcess", procName, &rebuilt)
+}
+
+// History is a wrapper that calls the remote History.
+// It returns a tuple with the builds and an error in case there's any.
+func (client *RemoteClient) History(procName string) (*HistoryResponse, error) {
+	response := &HistoryResponse{}
+	err := client.conn.Call("RemoteMaster.History", procName, response)
+	return response, err
+}
+
+// RollbackProcess is a wrapper that calls the remote RollbackProcess.
+// It returns a tuple with the version switched to and an error in case there's any.
+func (client *RemoteClient) RollbackProcess(procName string, version string) (string, error) {
+	var rolledBack string
+	err := client.conn.Call("RemoteMaster.RollbackProcess", &Rollback{Name: procName, Version: version}, &rolledBack)
+	return rolledBack, err
+}
+
 // StartProcess is a wrapper that calls the remote StartProcess.
 // It returns an error in case there's any.
 func (client *RemoteClient) StartProcess(procName string) error {
@@ -203,6 +405,32 @@ func (client *RemoteClient) StopProcess(procName string) error {
 	return client.conn.Call("RemoteMaster.StopProcess", procName, &stopped)
 }
 
+// ScaleProcess is a wrapper that calls the remote ScaleProcess.
+// It returns an error in case there's any.
+func (client *RemoteClient) ScaleProcess(procName string, instances int) error {
+	var scaled bool
+	scale := &Scale{
+		Name:      procName,
+		Instances: instances,
+	}
+	return client.conn.Call("RemoteMaster.ScaleProcess", scale, &scaled)
+}
+
+// FlushProcess is a wrapper that calls the remote FlushProcess.
+// It returns an error in case there's any.
+func (client *RemoteClient) FlushProcess(procName string) error {
+	var flushed bool
+	return client.conn.Call("RemoteMaster.FlushProcess", procName, &flushed)
+}
+
+// Logs is a wrapper that calls the remote Logs.
+// It returns a tuple with the lines read and an error in case there's any.
+func (client *RemoteClient) Logs(request *LogsRequest) (*LogsResponse, error) {
+	var response LogsResponse
+	err := client.conn.Call("RemoteMaster.Logs", request, &response)
+	return &response, err
+}
+
 // DeleteProcess is a wrapper that calls the remote DeleteProcess.
 // It returns an error in case there's any.
 func (client *RemoteClient) DeleteProcess(procName string) error {
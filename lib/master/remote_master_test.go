@@ -0,0 +1,22 @@
+package master
+
+import (
+	"testing"
+)
+
+func TestStartGoBinOfExistingProc(t *testing.T) {
+	master := newTestMaster(t)
+	proc := newTestProc(t, master, "app", "exec sleep 30")
+	remoteMaster := &RemoteMaster{master: master}
+
+	var ack bool
+	if err := remoteMaster.StartGoBin(&GoBin{Name: "app", SourcePath: "/tmp/app"}, &ack); err != ErrProcessExists {
+		t.Fatalf("got error %v, want %v", err, ErrProcessExists)
+	}
+	if proc.IsAlive() {
+		t.Fatal("the stopped proc was started by checking whether it exists")
+	}
+	if status := proc.GetStatus().Status; status != "stopped" {
+		t.Fatalf("got status %q, want stopped", status)
+	}
+}
@@ -0,0 +1,198 @@
+package master
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/struCoder/pmgo/lib/process"
+	"github.com/struCoder/pmgo/lib/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Resurrect results.
+const (
+	Restored        = "restored"
+	RestoredStopped = "restored stopped"
+	AlreadyExists   = "already exists"
+	Started         = "started"
+	Stopped         = "stopped"
+	StoppedNotSaved = "stopped, not saved"
+)
+
+// dump is the list of procs pmgo save writes, which Resurrect restores.
+type dump struct {
+	Procs map[string]process.ProcContainer
+}
+
+// decodableDump is the struct the dump file will decode to, see DecodableMaster.
+type decodableDump struct {
+	Procs map[string]*process.Proc
+}
+
+// ResurrectResult is the outcome of resurrecting a single proc.
+type ResurrectResult struct {
+	Name   string // Name is the proc name.
+	Result string // Result is Restored, RestoredStopped, AlreadyExists, Started, Stopped or StoppedNotSaved.
+	Error  string // Error is set when the proc could not be restored.
+}
+
+// ResurrectResponse is the outcome of Resurrect for every saved proc.
+type ResurrectResponse struct {
+	Procs []*ResurrectResult
+}
+
+// Resurrect will make the procs on pmgo match the ones saved by pmgo save. Saved procs that are
+// not on pmgo anymore are restored, rebuilding the binaries removed by delete from their source.
+// Procs are started or stopped as they were when saved, and running procs that were not saved
+// are stopped.
+// Returns a tuple with the result of every saved, or stopped, proc and an error in case there's any.
+func (master *Master) Resurrect() (*ResurrectResponse, error) {
+	saved := &decodableDump{Procs: make(map[string]*process.Proc)}
+	if _, err := os.Stat(master.getDumpPath()); os.IsNotExist(err) {
+		return nil, fmt.Errorf("no saved list of procs at %s, run pmgo save first", master.getDumpPath())
+	}
+	if err := utils.SafeReadTomlFile(master.getDumpPath(), saved); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(saved.Procs))
+	for name := range saved.Procs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	response := &ResurrectResponse{}
+	results := make(map[string]*ResurrectResult)
+	master.Lock()
+	existing := []string{}
+	missing := []*process.Proc{}
+	for _, name := range names {
+		result := &ResurrectResult{Name: name}
+		results[name] = result
+		response.Procs = append(response.Procs, result)
+		if _, ok := master.Procs[name]; ok {
+			existing = append(existing, name)
+			continue
+		}
+		missing = append(missing, saved.Procs[name])
+	}
+	master.Unlock()
+
+	// Binaries are built without holding the lock, once per cluster.
+	builds := make(map[string]error)
+	for _, proc := range missing {
+		group := groupName(proc)
+		if _, ok := builds[group]; ok {
+			continue
+		}
+		builds[group] = master.rebuildMissingBin(group, proc)
+	}
+
+	master.Lock()
+	defer master.Unlock()
+	for _, name := range existing {
+		result := results[name]
+		proc, ok := master.Procs[name]
+		if !ok {
+			result.Error = "deleted while resurrecting"
+			continue
+		}
+		result.Result, result.Error = master.reconcile(proc, isStopped(saved.Procs[name]))
+	}
+	for _, proc := range master.ListProcs() {
+		if _, ok := saved.Procs[proc.Identifier()]; ok || !proc.IsAlive() {
+			continue
+		}
+		result := &ResurrectResult{Name: proc.Identifier(), Result: StoppedNotSaved}
+		log.Infof("Stopping proc %s, which was not saved", proc.Identifier())
+		master.resetCrashState(proc)
+		if err := master.stop(proc); err != nil {
+			result.Result, result.Error = "", err.Error()
+		}
+		response.Procs = append(response.Procs, result)
+	}
+	for _, proc := range missing {
+		result := results[proc.Identifier()]
+		if err := builds[groupName(proc)]; err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		if _, ok := master.Procs[proc.Identifier()]; ok {
+			result.Result = AlreadyExists
+			continue
+		}
+		wasStopped := isStopped(proc)
+		proc.Status = &process.ProcStatus{}
+		proc.Pid = 0
+		master.Procs[proc.Identifier()] = proc
+		if wasStopped {
+			proc.SetStatus("stopped")
+			result.Result = RestoredStopped
+			continue
+		}
+		log.Infof("Resurrecting proc %s", proc.Identifier())
+		if err := master.start(proc); err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		result.Result = Restored
+	}
+	return response, master.saveProcsWrapper()
+}
+
+// reconcile will start, or stop, proc as it was when saved, stopped or not.
+// NOT thread safe method. Lock should be acquired before calling it.
+// Returns a tuple with the result and the error of the proc, if any.
+func (master *Master) reconcile(proc process.ProcContainer, stopped bool) (string, string) {
+	if stopped == !proc.IsAlive() {
+		return AlreadyExists, ""
+	}
+	master.resetCrashState(proc)
+	if stopped {
+		log.Infof("Stopping proc %s, which was stopped when saved", proc.Identifier())
+		if err := master.stop(proc); err != nil {
+			return "", err.Error()
+		}
+		return Stopped, ""
+	}
+	log.Infof("Starting proc %s, which was running when saved", proc.Identifier())
+	if err := master.start(proc); err != nil {
+		return "", err.Error()
+	}
+	return Started, ""
+}
+
+// isStopped will return true if proc was stopped when saved.
+func isStopped(proc *process.Proc) bool {
+	return proc.Status != nil && proc.Status.Status == "stopped"
+}
+
+// rebuildMissingBin will build the binary of the proc, or cluster, named name from its source
+// when it is missing, such as after a delete.
+// Returns an error in case there's any.
+func (master *Master) rebuildMissingBin(name string, proc *process.Proc) error {
+	if _, err := os.Stat(proc.Cmd); err == nil {
+		return nil
+	}
+	procPreparable, err := master.recipe(name, proc)
+	if err != nil {
+		return fmt.Errorf("binary %s is missing: %s", proc.Cmd, err)
+	}
+	log.Infof("Rebuilding missing binary of proc %s", name)
+	output, err := procPreparable.PrepareBin()
+	if err != nil {
+		return &BuildError{Err: err, Output: output}
+	}
+	return nil
+}
+
+// NOT Thread Safe. Lock should be acquired before calling it.
+func (master *Master) saveDump() error {
+	return utils.SafeWriteTomlFile(&dump{Procs: master.Procs}, master.getDumpPath())
+}
+
+func (master *Master) getDumpPath() string {
+	return path.Join(master.SysFolder, "dump.toml")
+}
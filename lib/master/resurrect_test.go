@@ -0,0 +1,122 @@
+package master
+
+import (
+	"testing"
+	"time"
+
+	"github.com/struCoder/pmgo/lib/process"
+	"github.com/struCoder/pmgo/lib/utils"
+)
+
+func TestResurrectMatchesDump(t *testing.T) {
+	master := newTestMaster(t)
+	for _, name := range []string{"same", "saved-running", "saved-stopped", "missing", "missing-stopped"} {
+		newTestProc(t, master, name, "exec sleep 30")
+	}
+	for _, name := range []string{"same", "saved-running", "missing"} {
+		if err := master.start(master.Procs[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	master.Procs["saved-stopped"].SetStatus("stopped")
+	master.Procs["missing-stopped"].SetStatus("stopped")
+	if err := master.saveDump(); err != nil {
+		t.Fatal(err)
+	}
+
+	// changes done after pmgo save
+	for _, name := range []string{"saved-running", "missing"} {
+		if err := master.stop(master.Procs[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	delete(master.Procs, "missing")
+	delete(master.Procs, "missing-stopped")
+	if err := master.start(master.Procs["saved-stopped"]); err != nil {
+		t.Fatal(err)
+	}
+	unsaved := newTestProc(t, master, "unsaved", "exec sleep 30")
+	if err := master.start(unsaved); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := master.Resurrect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"same":            AlreadyExists,
+		"saved-running":   Started,
+		"saved-stopped":   Stopped,
+		"missing":         Restored,
+		"missing-stopped": RestoredStopped,
+		"unsaved":         StoppedNotSaved,
+	}
+	if len(response.Procs) != len(want) {
+		t.Fatalf("got %d results, want %d", len(response.Procs), len(want))
+	}
+	for _, result := range response.Procs {
+		if result.Error != "" || result.Result != want[result.Name] {
+			t.Errorf("%s: got result %q and error %q, want %q", result.Name, result.Result, result.Error, want[result.Name])
+		}
+	}
+	running := map[string]bool{"same": true, "saved-running": true, "missing": true}
+	for name, proc := range master.Procs {
+		if proc.IsAlive() != running[name] {
+			t.Errorf("%s: got alive %t, want %t", name, proc.IsAlive(), running[name])
+		}
+	}
+	for _, proc := range master.Procs {
+		if proc.IsAlive() {
+			master.stop(proc)
+		}
+	}
+}
+
+func TestReviveAfterStop(t *testing.T) {
+	master := newTestMaster(t)
+	for _, name := range []string{"running", "stopped", "errored"} {
+		proc := newTestProc(t, master, name, "exec sleep 30")
+		proc.KeepAlive = true
+		if err := master.start(proc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := master.stop(master.Procs["stopped"]); err != nil {
+		t.Fatal(err)
+	}
+	master.Procs["errored"].ForceStop()
+	waitFor(t, 5*time.Second, "the errored proc to die", func() bool { return !master.Procs["errored"].IsAlive() })
+	master.Procs["errored"].SetStatus("errored")
+
+	if err := master.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for name, proc := range master.Procs {
+		if proc.IsAlive() {
+			t.Fatalf("%s is alive after Stop", name)
+		}
+	}
+
+	// the next daemon reads the procs saved by Stop
+	decodable := &DecodableMaster{Procs: make(map[string]*process.Proc)}
+	if err := utils.SafeReadTomlFile(master.getConfigPath(), decodable); err != nil {
+		t.Fatal(err)
+	}
+	next := newTestMaster(t)
+	for name, proc := range decodable.Procs {
+		if proc.Status.Status != name {
+			t.Errorf("%s: saved status %q after Stop", name, proc.Status.Status)
+		}
+		next.Procs[name] = proc
+	}
+	if err := next.Revive(); err != nil {
+		t.Fatal(err)
+	}
+	for name, proc := range next.Procs {
+		if proc.IsAlive() != (name == "running") {
+			t.Errorf("%s: got alive %t after Revive", name, proc.IsAlive())
+		}
+	}
+	next.stop(next.Procs["running"])
+}
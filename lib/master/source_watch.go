@@ -0,0 +1,106 @@
+package master
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/struCoder/pmgo/lib/process"
+	"github.com/struCoder/pmgo/lib/sourcewatch"
+)
+
+// maxSourceRetryDelay is the longest wait before watching again a source tree that could not be watched.
+const maxSourceRetryDelay = time.Minute
+
+// sourceState is the watch of the source tree of a proc, or cluster.
+type sourceState struct {
+	path    string               // path is the watched source tree.
+	watcher *sourcewatch.Watcher // watcher is nil when the tree could not be watched.
+	delay   time.Duration        // delay is the wait before the next attempt, doubled on every failure.
+	retryAt time.Time            // retryAt is when the tree is watched again, while watcher is nil.
+}
+
+// SourceWatches will loop, until pmgo stops, keeping a source watcher on every proc, or cluster, started with
+// SourceWatch enabled, and closing the ones of deleted procs. Trees that could not be watched are
+// tried again, waiting twice as long after every failure up to maxSourceRetryDelay.
+func (master *Master) SourceWatches() {
+	for {
+		master.Lock()
+		if master.stopping {
+			master.Unlock()
+			return
+		}
+		wanted := make(map[string]process.ProcContainer)
+		for _, proc := range master.ListProcs() {
+			if proc.GetSourceWatch().Enabled && proc.GetSourcePath() != "" {
+				wanted[groupName(proc)] = proc
+			}
+		}
+		for name, state := range master.sources {
+			if proc, ok := wanted[name]; !ok || proc.GetSourcePath() != state.path {
+				if state.watcher != nil {
+					state.watcher.Close()
+				}
+				delete(master.sources, name)
+			}
+		}
+		now := time.Now()
+		for name, proc := range wanted {
+			state, ok := master.sources[name]
+			if ok && (state.watcher != nil || now.Before(state.retryAt)) {
+				continue
+			}
+			if !ok {
+				state = &sourceState{path: proc.GetSourcePath(), delay: time.Second}
+				master.sources[name] = state
+			}
+			name := name
+			watcher, err := sourcewatch.New(state.path, proc.GetSourceWatch(), func(files []string) {
+				master.rebuild(name, files)
+			})
+			if err != nil {
+				log.Errorf("Could not watch the source of proc %s due to %s, retrying in %s", name, err, state.delay)
+				state.retryAt = now.Add(state.delay)
+				state.delay *= 2
+				if state.delay > maxSourceRetryDelay {
+					state.delay = maxSourceRetryDelay
+				}
+				continue
+			}
+			log.Infof("Watching the source of proc %s on %s", name, state.path)
+			state.watcher = watcher
+		}
+		master.Unlock()
+		time.Sleep(time.Second)
+	}
+}
+
+// rebuild will build the proc, or cluster, named name from its source and restart it with the new
+// binary. When the build fails, the previous binary is kept running.
+func (master *Master) rebuild(name string, files []string) {
+	log.Infof("Source of proc %s changed (%s), rebuilding.", name, strings.Join(files, ", "))
+	err := master.rebuildProcess(name, "source changed")
+	if _, ok := err.(*BuildError); ok {
+		log.Errorf("Failed to rebuild proc %s, keeping the running binary. %s", name, err)
+	} else if err != nil && err != ErrUnknownProcess {
+		log.Warnf("Could not restart process %s due to %s.", name, err)
+	}
+}
+
+// groupName will return the cluster name of proc, or its name when it is not on a cluster.
+func groupName(proc process.ProcContainer) string {
+	if cluster := proc.GetCluster(); cluster != "" {
+		return cluster
+	}
+	return proc.Identifier()
+}
+
+func formatSourceWatch(watch sourcewatch.Config) string {
+	debounce := watch.Debounce
+	if debounce <= 0 {
+		debounce = sourcewatch.DefaultDebounce
+	}
+	ignore := append(append([]string{}, sourcewatch.DefaultIgnore...), watch.Ignore...)
+	return fmt.Sprintf("debounce %s, ignoring %s", debounce, strings.Join(ignore, ", "))
+}
@@ -0,0 +1,88 @@
+package master
+
+import (
+	"net"
+	"net/rpc"
+	"os"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// unixPrefix is the dsn prefix of unix socket addresses, as in unix:///home/me/.pmgo/pmgo.sock.
+const unixPrefix = "unix://"
+
+// SocketFile will return the unix socket path of the daemon using configFile.
+func SocketFile(configFile string) string {
+	return path.Join(path.Dir(configFile), "pmgo.sock")
+}
+
+// UnixDsn will return the dsn StartRemoteClient uses to connect to the unix socket socketFile.
+func UnixDsn(socketFile string) string {
+	return unixPrefix + socketFile
+}
+
+// splitDsn will return the network and address of dsn, unix for unix:// dsns and tcp otherwise.
+func splitDsn(dsn string) (string, string) {
+	if strings.HasPrefix(dsn, unixPrefix) {
+		return "unix", strings.TrimPrefix(dsn, unixPrefix)
+	}
+	return "tcp", dsn
+}
+
+// listenUnix will listen on the unix socket socketFile, only readable and writable by the daemon user.
+// A socket file left by a previous daemon is removed first.
+// Returns a tuple with the listener and an error in case there's any.
+func listenUnix(socketFile string) (net.Listener, error) {
+	if err := os.Remove(socketFile); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", socketFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketFile, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// acceptUnix will serve the RPC connections of l. When allowedUIDs is not empty, only peers
+// running as the daemon user or one of allowedUIDs are served.
+func acceptUnix(l net.Listener, allowedUIDs []int) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Errorf("Failed to accept unix socket connection due to %s", err)
+			return
+		}
+		if len(allowedUIDs) > 0 {
+			uid, err := peerUID(conn)
+			if err != nil {
+				log.Warnf("Rejected unix socket connection: %s", err)
+				conn.Close()
+				continue
+			}
+			if !isAllowedUID(uid, allowedUIDs) {
+				log.Warnf("Rejected unix socket connection from uid %d", uid)
+				conn.Close()
+				continue
+			}
+		}
+		go rpc.ServeConn(conn)
+	}
+}
+
+func isAllowedUID(uid int, allowedUIDs []int) bool {
+	if uid == os.Getuid() {
+		return true
+	}
+	for _, allowed := range allowedUIDs {
+		if uid == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,23 @@
 package preparable
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/struCoder/pmgo/lib/artifact"
+	"github.com/struCoder/pmgo/lib/build"
+	"github.com/struCoder/pmgo/lib/cgroup"
+	"github.com/struCoder/pmgo/lib/health"
+	"github.com/struCoder/pmgo/lib/logrotate"
 	"github.com/struCoder/pmgo/lib/process"
+	"github.com/struCoder/pmgo/lib/sourcewatch"
+	"github.com/struCoder/pmgo/lib/utils"
 )
 
 // ProcPreparable is a preparable with all the necessary informations to run
@@ -12,6 +25,8 @@ import (
 type ProcPreparable interface {
 	PrepareBin() ([]byte, error)
 	Start() (process.ProcContainer, error)
+	StartInstance(instance int) (process.ProcContainer, error)
+	GetInstances() int
 	getPath() string
 	Identifier() string
 	getBinPath() string
@@ -28,26 +43,170 @@ type Preparable struct {
 	Language   string
 	KeepAlive  bool
 	Args       []string
+	Env        map[string]string
+	EnvFiles   []string
+	CleanEnv   bool
+	Cwd        string
+	LogRotate  logrotate.Config
+
+	RestartPolicy process.RestartPolicy
+	StopSignal    string
+	KillTimeout   time.Duration
+	Sockets       []string
+	Instances     int // Instances is the number of processes started on cluster mode. 0 disables it.
+	HealthCheck   health.Check
+
+	MaxMemory        int64
+	MaxMemorySamples int
+	CronRestart      string
+	SourceWatch      sourcewatch.Config
+	Build            build.Spec // Build is how SourcePath is compiled on the go runtime.
+	KeepBuilds       int        // KeepBuilds is the number of builds kept for rollbacks.
+
+	User   string
+	Group  string
+	Groups []string
+	Cgroup cgroup.Limits
+
+	Interpreter string // Interpreter runs the script on SourcePath on the interpreter runtime. Defaults from its extension.
+	Copy        bool   // Copy copies the binary on SourcePath into the proc folder on the exec runtime.
 }
 
-// PrepareBin will compile the Golang project from SourcePath and populate Cmd with the proper
-// command for the process to be executed.
+// defaultInterpreters are the interpreters of the scripts started without one, by extension.
+var defaultInterpreters = map[string]string{
+	".py":  "python3",
+	".sh":  "bash",
+	".js":  "node",
+	".rb":  "ruby",
+	".pl":  "perl",
+	".php": "php",
+}
+
+// PrepareBin will compile the Golang project from SourcePath, as described by Build, and populate
+// Cmd with the proper command for the process to be executed. The binary is built next to the
+// previous one and only replaces it when the build succeeds, and it is kept as a versioned build
+// for rollbacks. The exec and interpreter runtimes don't build anything.
 // Returns the compile command output.
 func (preparable *Preparable) PrepareBin() ([]byte, error) {
+	// the proc folder holds the pid and log files even when nothing is built into it
+	if err := os.MkdirAll(preparable.getPath(), 0777); err != nil {
+		return nil, err
+	}
+	// builds of the same proc, or cluster, such as a rebuild racing a watched source change,
+	// run one after the other
+	unlock := lockBuild(preparable.getBinPath())
+	defer unlock()
+	switch preparable.Language {
+	case process.RuntimeExec:
+		return nil, preparable.prepareExec()
+	case process.RuntimeInterpreter:
+		return nil, preparable.prepareInterpreter()
+	}
 	// Remove the last character '/' if present
 	if preparable.SourcePath[len(preparable.SourcePath)-1] == '/' {
 		preparable.SourcePath = strings.TrimSuffix(preparable.SourcePath, "/")
 	}
-	cmd := ""
-	cmdArgs := []string{}
 	binPath := preparable.getBinPath()
-	if preparable.Language == "go" {
-		cmd = "go"
-		cmdArgs = []string{"build", "-o", binPath, preparable.SourcePath + "/."}
+	buildDir, err := preparable.tempDir()
+	if err != nil {
+		return nil, err
 	}
+	defer os.RemoveAll(buildDir)
+	buildPath := filepath.Join(buildDir, preparable.Name)
 
 	preparable.Cmd = preparable.getBinPath()
-	return exec.Command(cmd, cmdArgs...).Output()
+	output, err := preparable.Build.Command(preparable.SourcePath, buildPath).CombinedOutput()
+	if err != nil {
+		return output, err
+	}
+	built := artifact.Describe(preparable.SourcePath, preparable.Build.String())
+	if err := artifact.Add(preparable.getBuildsPath(), buildPath, built, binPath, preparable.KeepBuilds); err != nil {
+		return output, err
+	}
+	return output, nil
+}
+
+// prepareExec will check the binary on SourcePath and, when Copy is set, copy it into the proc
+// folder so later changes to the original don't affect the process.
+// Returns an error in case there's any.
+func (preparable *Preparable) prepareExec() error {
+	info, err := os.Stat(preparable.SourcePath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("%s is not an executable file", preparable.SourcePath)
+	}
+	if !preparable.Copy {
+		preparable.Cmd = preparable.SourcePath
+		return nil
+	}
+	binPath := preparable.getBinPath()
+	copyDir, err := preparable.tempDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(copyDir)
+	copyPath := filepath.Join(copyDir, preparable.Name)
+	if err := utils.CopyFile(preparable.SourcePath, copyPath); err != nil {
+		return err
+	}
+	preparable.Cmd = binPath
+	return os.Rename(copyPath, binPath)
+}
+
+// tempDir will create a folder of its own for a build on the proc folder, so concurrent builds
+// never write the same file and the binary is moved in place without leaving the file system.
+// Returns a tuple with the folder and an error in case there's any.
+func (preparable *Preparable) tempDir() (string, error) {
+	return ioutil.TempDir(preparable.getPath(), ".build-")
+}
+
+// buildLocks are the locks of the builds in progress, keyed by binary path.
+var buildLocks = struct {
+	sync.Mutex
+	locks map[string]*sync.Mutex
+}{locks: make(map[string]*sync.Mutex)}
+
+// lockBuild will wait for the other builds of binPath to finish.
+// Returns the function releasing the lock.
+func lockBuild(binPath string) func() {
+	buildLocks.Lock()
+	lock, ok := buildLocks.locks[binPath]
+	if !ok {
+		lock = &sync.Mutex{}
+		buildLocks.locks[binPath] = lock
+	}
+	buildLocks.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// prepareInterpreter will check the script on SourcePath and resolve its interpreter on the
+// daemon PATH.
+// Returns an error in case there's any.
+func (preparable *Preparable) prepareInterpreter() error {
+	info, err := os.Stat(preparable.SourcePath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is not a script", preparable.SourcePath)
+	}
+	interpreter := preparable.Interpreter
+	if interpreter == "" {
+		interpreter = defaultInterpreters[filepath.Ext(preparable.SourcePath)]
+	}
+	if interpreter == "" {
+		return fmt.Errorf("no interpreter given for %s", preparable.SourcePath)
+	}
+	path, err := exec.LookPath(interpreter)
+	if err != nil {
+		return err
+	}
+	preparable.Interpreter = path
+	preparable.Cmd = preparable.SourcePath
+	return nil
 }
 
 // Start will execute the process based on the information presented on the preparable.
@@ -55,7 +214,30 @@ func (preparable *Preparable) PrepareBin() ([]byte, error) {
 // all the watchers and process handling are done correctly.
 // Returns a tuple with the process and an error in case there's any.
 func (preparable *Preparable) Start() (process.ProcContainer, error) {
-	proc := &process.Proc{
+	proc := preparable.newProc()
+	err := proc.Start()
+	return proc, err
+}
+
+// StartInstance will execute the instance of the cluster based on the information presented on the preparable.
+// Every instance has its own pid, out and err files and the INSTANCE_ID environment variable.
+// Returns a tuple with the process and an error in case there's any.
+func (preparable *Preparable) StartInstance(instance int) (process.ProcContainer, error) {
+	cluster := preparable.newProc()
+	cluster.Cluster = preparable.Name
+	// cloned, so the instances don't share the slices and maps of the preparable
+	proc := cluster.Clone(instance)
+	err := proc.Start()
+	return proc, err
+}
+
+// GetInstances will return the number of instances to start on cluster mode, 0 if disabled.
+func (preparable *Preparable) GetInstances() int {
+	return preparable.Instances
+}
+
+func (preparable *Preparable) newProc() *process.Proc {
+	return &process.Proc{
 		Name:      preparable.Name,
 		Cmd:       preparable.Cmd,
 		Args:      preparable.Args,
@@ -64,11 +246,36 @@ func (preparable *Preparable) Start() (process.ProcContainer, error) {
 		Outfile:   preparable.getOutPath(),
 		Errfile:   preparable.getErrPath(),
 		KeepAlive: preparable.KeepAlive,
+		Env:       preparable.Env,
+		EnvFiles:  preparable.EnvFiles,
+		CleanEnv:  preparable.CleanEnv,
+		Cwd:       preparable.Cwd,
+		LogRotate: preparable.LogRotate,
 		Status:    &process.ProcStatus{},
-	}
 
-	err := proc.Start()
-	return proc, err
+		RestartPolicy: preparable.RestartPolicy,
+		StopSignal:    preparable.StopSignal,
+		KillTimeout:   preparable.KillTimeout,
+		Sockets:       preparable.Sockets,
+		HealthCheck:   preparable.HealthCheck,
+
+		MaxMemory:        preparable.MaxMemory,
+		MaxMemorySamples: preparable.MaxMemorySamples,
+		CronRestart:      preparable.CronRestart,
+		SourcePath:       preparable.SourcePath,
+		SourceWatch:      preparable.SourceWatch,
+		Build:            preparable.Build,
+		KeepBuilds:       preparable.KeepBuilds,
+
+		User:   preparable.User,
+		Group:  preparable.Group,
+		Groups: preparable.Groups,
+		Cgroup: preparable.Cgroup,
+
+		Runtime:     preparable.Language,
+		Interpreter: preparable.Interpreter,
+		Copy:        preparable.Copy,
+	}
 }
 
 // Identifier is a function that get proc name
@@ -87,6 +294,10 @@ func (preparable *Preparable) getBinPath() string {
 	return preparable.getPath() + "/" + preparable.Name
 }
 
+func (preparable *Preparable) getBuildsPath() string {
+	return artifact.Dir(preparable.getPath())
+}
+
 func (preparable *Preparable) getPidPath() string {
 	return preparable.getBinPath() + ".pid"
 }
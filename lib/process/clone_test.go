@@ -0,0 +1,29 @@
+package process
+
+import (
+	"testing"
+)
+
+func TestCloneCopiesSettings(t *testing.T) {
+	proc := &Proc{
+		Name:    "api:0",
+		Cluster: "api",
+		Path:    "/tmp/api",
+		Args:    []string{"--port", "80"},
+		Env:     map[string]string{"A": "a"},
+		Sockets: []string{"tcp://:80"},
+		Status:  &ProcStatus{},
+	}
+	proc.Build.Env = map[string]string{"CGO_ENABLED": "0"}
+	clone := proc.Clone(1).(*Proc)
+	clone.Args[1] = "81"
+	clone.Env["A"] = "b"
+	clone.Sockets[0] = "tcp://:81"
+	clone.Build.Env["CGO_ENABLED"] = "1"
+	if proc.Args[1] != "80" || proc.Env["A"] != "a" || proc.Sockets[0] != "tcp://:80" || proc.Build.Env["CGO_ENABLED"] != "0" {
+		t.Fatalf("changing the clone changed the original: %+v", proc)
+	}
+	if clone.Name != "api:1" || clone.InstanceID != 1 || clone.Outfile != "/tmp/api/api-1.out" {
+		t.Fatalf("unexpected clone %s %d %s", clone.Name, clone.InstanceID, clone.Outfile)
+	}
+}
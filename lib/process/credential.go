@@ -0,0 +1,92 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Credential will resolve the user and groups, given by name or id, a process runs as. The groups
+// of username are used when no groups are given. Switching users requires the daemon to run as root.
+// Returns a tuple with the credential, nil when the process runs as the daemon user, and an error in case there's any.
+func Credential(username string, group string, groups []string) (*syscall.Credential, error) {
+	if username == "" && group == "" && len(groups) == 0 {
+		return nil, nil
+	}
+	credential := &syscall.Credential{
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	}
+	var groupIds []string
+	if username != "" {
+		u, err := lookupUser(username)
+		if err != nil {
+			return nil, err
+		}
+		uid, _ := strconv.Atoi(u.Uid)
+		gid, _ := strconv.Atoi(u.Gid)
+		credential.Uid = uint32(uid)
+		credential.Gid = uint32(gid)
+		// not every platform lists the groups of a user, it then only has its primary group
+		groupIds, _ = u.GroupIds()
+	}
+	if group != "" {
+		gid, err := lookupGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		credential.Gid = gid
+	}
+	if len(groups) > 0 {
+		groupIds = groups
+	}
+	for _, name := range groupIds {
+		gid, err := lookupGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		credential.Groups = append(credential.Groups, gid)
+	}
+	if os.Geteuid() != 0 {
+		if int(credential.Uid) == os.Geteuid() && int(credential.Gid) == os.Getegid() && len(groups) == 0 {
+			// already the daemon user, nothing to switch
+			return nil, nil
+		}
+		return nil, fmt.Errorf("running as user %q and group %q requires the pmgo daemon to run as root, it runs as uid %d",
+			username, group, os.Geteuid())
+	}
+	return credential, nil
+}
+
+// FormatCredential will return the user, group and groups a process runs as, for display.
+func FormatCredential(username string, group string, groups []string) string {
+	formatted := username
+	if group != "" {
+		formatted += ":" + group
+	}
+	for _, name := range groups {
+		formatted += "," + name
+	}
+	return formatted
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return user.LookupId(name)
+	}
+	return user.Lookup(name)
+}
+
+func lookupGroup(name string) (uint32, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return uint32(id), nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(g.Gid)
+	return uint32(id), err
+}
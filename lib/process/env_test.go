@@ -0,0 +1,67 @@
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "first.env")
+	second := filepath.Join(dir, "second.env")
+	ioutil.WriteFile(first, []byte("A=first\nB=first\n"), 0600)
+	ioutil.WriteFile(second, []byte("B=second\nC=second\n"), 0600)
+
+	proc := &Proc{Name: "app", EnvFiles: []string{first, second}, Env: map[string]string{"C": "flag"}}
+	env, err := proc.GetEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "first", "B": "second", "C": "flag"}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("got env %v, want %v", env, want)
+	}
+
+	os.Remove(second)
+	if _, err := proc.GetEnv(); err == nil || !strings.Contains(err.Error(), second) {
+		t.Fatalf("got error %v for a removed env file", err)
+	}
+}
+
+func TestEnvironHidesDaemonEnv(t *testing.T) {
+	t.Setenv("PMGO_TOKEN", "secret")
+	tests := []struct {
+		name     string
+		cleanEnv bool
+	}{
+		{name: "inherited"},
+		{name: "clean", cleanEnv: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			proc := &Proc{Name: "app", Cluster: "app", InstanceID: 1, CleanEnv: test.cleanEnv, Env: map[string]string{"PORT": "80"}}
+			env, set, err := proc.buildEnviron()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"INSTANCE_ID=1", "PORT=80"}; !reflect.DeepEqual(set, want) {
+				t.Fatalf("got pmgo variables %v, want %v", set, want)
+			}
+			inherited := false
+			for _, kv := range env {
+				inherited = inherited || kv == "PMGO_TOKEN=secret"
+			}
+			if inherited == test.cleanEnv {
+				t.Fatalf("got daemon variable inherited %t with CleanEnv %t", inherited, test.cleanEnv)
+			}
+		})
+	}
+}
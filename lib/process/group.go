@@ -0,0 +1,37 @@
+package process
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// ProcessNode is a process of the tree under a proc, such as the workers it forked.
+type ProcessNode struct {
+	Pid      int
+	Command  string
+	Children []*ProcessNode
+}
+
+// SignalGroup will send signal to the process group pid leads, so the children it forked get it
+// too. Procs started before they got their own group only have pid signaled.
+// The pid must be read before the process is released, which sets it to -1: kill(2) would
+// signal every process, or init, otherwise.
+// Returns an error in case there's any.
+func SignalGroup(pid int, signal syscall.Signal) error {
+	if pid <= 0 {
+		return fmt.Errorf("invalid pid %d", pid)
+	}
+	if err := syscall.Kill(-pid, signal); err != syscall.ESRCH {
+		return err
+	}
+	return syscall.Kill(pid, signal)
+}
+
+// killGroup will kill whatever is left on the process group of the dead leader pid, so children
+// don't keep its ports and files after it is gone.
+func killGroup(pid int) {
+	if pid <= 0 {
+		return
+	}
+	syscall.Kill(-pid, syscall.SIGKILL)
+}
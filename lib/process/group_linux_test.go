@@ -0,0 +1,130 @@
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalGroupRefusesInvalidPids(t *testing.T) {
+	for _, pid := range []int{0, -1} {
+		// kill(2) would signal init, or every process, with these
+		if err := SignalGroup(pid, syscall.Signal(0)); err == nil {
+			t.Errorf("SignalGroup(%d) returned no error", pid)
+		}
+	}
+}
+
+func TestSignalGroupReachesChildren(t *testing.T) {
+	tests := []struct {
+		name   string
+		signal func(leader int) error
+	}{
+		{name: "stop signal", signal: func(leader int) error { return SignalGroup(leader, syscall.SIGTERM) }},
+		{name: "kill", signal: func(leader int) error { return SignalGroup(leader, syscall.SIGKILL) }},
+		{name: "kill group of a dead leader", signal: func(leader int) error {
+			syscall.Kill(leader, syscall.SIGKILL)
+			time.Sleep(100 * time.Millisecond)
+			killGroup(leader)
+			return nil
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			leader, child := startGroup(t)
+			if err := test.signal(leader.Process.Pid); err != nil {
+				t.Fatal(err)
+			}
+			leader.Wait()
+			if !waitDead(child) {
+				syscall.Kill(child, syscall.SIGKILL)
+				t.Errorf("child %d of the group is still alive", child)
+			}
+		})
+	}
+}
+
+func TestWatchKillsLeftChildren(t *testing.T) {
+	leader, child := startGroup(t)
+	proc := &Proc{process: leader.Process, Pid: leader.Process.Pid}
+	syscall.Kill(proc.Pid, syscall.SIGKILL)
+	if _, err := proc.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	if !waitDead(child) {
+		syscall.Kill(child, syscall.SIGKILL)
+		t.Errorf("child %d of the dead leader is still alive", child)
+	}
+}
+
+func TestForceStopOfWatchedProcess(t *testing.T) {
+	leader, child := startGroup(t)
+	proc := &Proc{process: leader.Process, Pid: leader.Process.Pid, Status: &ProcStatus{}}
+	watched := make(chan error, 1)
+	go func() {
+		_, err := proc.Watch()
+		watched <- err
+	}()
+	if err := proc.ForceStop(); err != nil {
+		syscall.Kill(-leader.Process.Pid, syscall.SIGKILL)
+		t.Fatal(err)
+	}
+	select {
+	case <-watched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not return after ForceStop")
+	}
+	if !waitDead(child) {
+		t.Errorf("child %d is still alive after ForceStop", child)
+	}
+}
+
+// startGroup will start a shell on its own process group, forking a sleep.
+// Returns the shell command and the pid of the sleep.
+func startGroup(t *testing.T) (*exec.Cmd, int) {
+	dir, err := ioutil.TempDir("", "group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "child.pid")
+	cmd := exec.Command("sh", "-c", "sleep 30 & echo $! > "+pidFile+"; wait")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		content, err := ioutil.ReadFile(pidFile)
+		if err != nil || !strings.HasSuffix(string(content), "\n") {
+			continue
+		}
+		child, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cmd, child
+	}
+	cmd.Process.Kill()
+	t.Fatal("the shell did not fork its child")
+	return nil, 0
+}
+
+// waitDead will wait up to a few seconds for pid to be gone, or be a zombie.
+func waitDead(pid int) bool {
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		stat, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if err != nil {
+			return true
+		}
+		if fields := strings.Fields(string(stat)); len(fields) > 2 && fields[2] == "Z" {
+			return true
+		}
+	}
+	return false
+}
@@ -2,16 +2,28 @@ package process
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/struCoder/pmgo/lib/build"
+	"github.com/struCoder/pmgo/lib/cgroup"
+	"github.com/struCoder/pmgo/lib/health"
+	"github.com/struCoder/pmgo/lib/logrotate"
+	"github.com/struCoder/pmgo/lib/sourcewatch"
 	"github.com/struCoder/pmgo/lib/utils"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // ProcContainer is a interface that about proc
 type ProcContainer interface {
 	Start() error
+	Reload() (*os.Process, error)
 	ForceStop() error
 	GracefullyStop() error
 	Restart() error
@@ -19,20 +31,48 @@ type ProcContainer interface {
 	IsAlive() bool
 	Identifier() string
 	ShouldKeepAlive() bool
-	AddRestart()
+	AddRestart(reason string)
 	NotifyStopped()
 	SetStatus(status string)
 	SetUptime()
 	SetSysInfo()
+	SetExitCode(code int)
+	SetHealth(result health.Result)
 	GetPid() int
 	GetStatus() *ProcStatus
 	Watch() (*os.ProcessState, error)
+	GetChildren() ([]*ProcessNode, error)
 	release()
 	GetOutFile() string
 	GetPidFile() string
 	GetPath() string
 	GetErrFile() string
+	GetLogWriters() (*logrotate.Writer, *logrotate.Writer)
 	GetName() string
+	GetEnv() (map[string]string, error)
+	GetEnviron() []string
+	GetCleanEnv() bool
+	GetCwd() string
+	Environ() ([]string, error)
+	Flush() error
+	GetRestartPolicy() RestartPolicy
+	GetStopSignal() string
+	GetKillTimeout() time.Duration
+	GetSockets() []string
+	GetHealthCheck() health.Check
+	GetMaxMemory() (int64, int)
+	GetCronRestart() string
+	GetCredential() string
+	GetCgroup() cgroup.Limits
+	GetRuntime() (string, string)
+	GetCopy() bool
+	GetSourcePath() string
+	GetBuild() build.Spec
+	GetKeepBuilds() int
+	GetSourceWatch() sourcewatch.Config
+	GetCluster() string
+	GetInstanceID() int
+	Clone(instance int) ProcContainer
 }
 
 // Proc is a os.Process wrapper with Status and more info that will be used on Master to maintain
@@ -46,40 +86,151 @@ type Proc struct {
 	Outfile   string
 	Errfile   string
 	KeepAlive bool
+	Env       map[string]string
+	EnvFiles  []string
+	CleanEnv  bool
+	Cwd       string
+	LogRotate logrotate.Config
 	Pid       int
 	Status    *ProcStatus
 	process   *os.Process
+	outLog    *logrotate.Writer
+	errLog    *logrotate.Writer
+	environ   []string
+
+	RestartPolicy RestartPolicy
+	StopSignal    string        // StopSignal is sent by GracefullyStop. Defaults to SIGTERM.
+	KillTimeout   time.Duration // KillTimeout is how long the process may take to stop before it is killed.
+	Sockets       []string      // Sockets are listened by pmgo and inherited by the process from fd 3 on.
+	sockets       []*os.File
+
+	Cluster    string // Cluster is the name of the group of instances this proc belongs to, if any.
+	InstanceID int    // InstanceID is the proc index on its cluster.
+
+	HealthCheck health.Check // HealthCheck probes the process while it runs. It is restarted after repeated failures.
+
+	MaxMemory        int64 // MaxMemory is the RSS in bytes above which the process is restarted. 0 disables it.
+	MaxMemorySamples int   // MaxMemorySamples is the number of samples in a row above MaxMemory before a restart.
+
+	CronRestart string // CronRestart is a cron expression the process is restarted on, such as "0 4 * * *".
+
+	SourcePath  string             // SourcePath is the source directory the binary was built from.
+	SourceWatch sourcewatch.Config // SourceWatch rebuilds and restarts the process when SourcePath changes.
+	Build       build.Spec         // Build is how SourcePath is compiled on the go runtime.
+	KeepBuilds  int                // KeepBuilds is the number of builds kept for rollbacks.
+
+	User   string   // User is the user, name or uid, the process runs as. Defaults to the daemon user.
+	Group  string   // Group is the group, name or gid, the process runs as. Defaults to the User primary group.
+	Groups []string // Groups are the supplementary groups of the process. Defaults to the User groups.
+
+	Cgroup cgroup.Limits // Cgroup are the hard resource limits enforced on the process cgroup.
+
+	Runtime     string // Runtime is how the process is started: go, exec or interpreter. Empty is go.
+	Interpreter string // Interpreter is the program running the Cmd script on the interpreter runtime.
+	Copy        bool   // Copy is set when the exec runtime binary was copied into Path.
+}
+
+// InstanceName will return the name of the instance of a cluster, such as api:0.
+func InstanceName(cluster string, instance int) string {
+	return cluster + ":" + strconv.Itoa(instance)
+}
+
+// InstanceFile will return the path of a file of the instance of a cluster inside the cluster folder.
+func InstanceFile(path string, cluster string, instance int, ext string) string {
+	return path + "/" + cluster + "-" + strconv.Itoa(instance) + ext
 }
 
 // Start will execute the command Cmd that should run the process. It will also create an out, err and pidfile
-// in case they do not exist yet.
+// in case they do not exist yet. The process stdout and stderr are pipes owned by pmgo, copied into
+// the out and err files so they can be rotated while the process keeps running.
 // Returns an error in case there's any.
 func (proc *Proc) Start() error {
-	outFile, err := utils.GetFile(proc.Outfile)
+	env, procEnv, err := proc.buildEnviron()
+	if err != nil {
+		return err
+	}
+	credential, err := Credential(proc.User, proc.Group, proc.Groups)
 	if err != nil {
 		return err
 	}
-	errFile, err := utils.GetFile(proc.Errfile)
+	if err := proc.openSockets(); err != nil {
+		return err
+	}
+	outLog, err := logrotate.Open(proc.Outfile, proc.LogRotate)
+	if err != nil {
+		return err
+	}
+	errLog, err := logrotate.Open(proc.Errfile, proc.LogRotate)
 	if err != nil {
+		outLog.Close()
 		return err
 	}
-	wd, _ := os.Getwd()
+	if credential != nil {
+		// the logs and the binary folder belong to the process user, so it can read them
+		uid, gid := int(credential.Uid), int(credential.Gid)
+		os.Chown(proc.Path, uid, gid)
+		if proc.ownsCmd() {
+			os.Chown(proc.Cmd, uid, gid)
+		}
+		outLog.Chown(uid, gid)
+		errLog.Chown(uid, gid)
+	}
+	outRead, outWrite, err := os.Pipe()
+	if err != nil {
+		outLog.Close()
+		errLog.Close()
+		return err
+	}
+	errRead, errWrite, err := os.Pipe()
+	if err != nil {
+		outRead.Close()
+		outWrite.Close()
+		outLog.Close()
+		errLog.Close()
+		return err
+	}
+	wd := proc.Cwd
+	// procs saved before Cwd existed keep running from the daemon working directory
+	if wd == "" {
+		wd, _ = os.Getwd()
+	}
 	procAtr := &os.ProcAttr{
 		Dir: wd,
-		Env: os.Environ(),
-		Files: []*os.File{
+		Env: env,
+		// its own process group, so stopping it reaches the children it forks too
+		Sys: &syscall.SysProcAttr{Setpgid: true, Credential: credential},
+		Files: append([]*os.File{
 			os.Stdin,
-			outFile,
-			errFile,
-		},
+			outWrite,
+			errWrite,
+		}, proc.sockets...),
+	}
+	cmd, args := proc.command()
+	if len(proc.sockets) > 0 {
+		args = append([]string{"sh", "-c", socketWrapper, cmd}, args[1:]...)
+		cmd = "/bin/sh"
 	}
-	args := append([]string{proc.Name}, proc.Args...)
-	process, err := os.StartProcess(proc.Cmd, args, procAtr)
+	process, err := proc.startProcess(cmd, args, procAtr)
+	// the child holds its own copy of the write ends now
+	outWrite.Close()
+	errWrite.Close()
 	if err != nil {
+		outRead.Close()
+		errRead.Close()
+		outLog.Close()
+		errLog.Close()
+		if credential != nil && os.IsPermission(err) {
+			return fmt.Errorf("%s, the binary and working directory must be reachable by user %s", err, FormatCredential(proc.User, proc.Group, proc.Groups))
+		}
 		return err
 	}
+	go outLog.Copy(outRead)
+	go errLog.Copy(errRead)
+	proc.outLog = outLog
+	proc.errLog = errLog
 	proc.process = process
 	proc.Pid = proc.process.Pid
+	proc.environ = procEnv
 	err = utils.WriteFile(proc.Pidfile, []byte(strconv.Itoa(proc.process.Pid)))
 	if err != nil {
 		return err
@@ -89,11 +240,65 @@ func (proc *Proc) Start() error {
 	return nil
 }
 
-// ForceStop will forcefully send a SIGKILL signal to process killing it instantly.
+// Environ will return the environment the process is started with: the daemon environment,
+// unless CleanEnv is set, followed by the variables returned by GetEnv, so the latter take precedence.
+// Returns a tuple with the environment and an error in case there's any.
+func (proc *Proc) Environ() ([]string, error) {
+	env, _, err := proc.buildEnviron()
+	return env, err
+}
+
+// buildEnviron will return the environment the process is started with, see Environ, and the
+// variables pmgo sets on it, without the daemon ones.
+// Returns a tuple with the environment, the variables pmgo sets and an error in case there's any.
+func (proc *Proc) buildEnviron() ([]string, []string, error) {
+	procEnv, err := proc.GetEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range proc.socketEnv() {
+		procEnv[k] = v
+	}
+	if proc.Cluster != "" {
+		procEnv["INSTANCE_ID"] = strconv.Itoa(proc.InstanceID)
+	}
+	env := []string{}
+	if !proc.CleanEnv {
+		for _, kv := range os.Environ() {
+			// os.StartProcess keeps duplicated variables and most programs read the first one
+			if _, overridden := procEnv[strings.SplitN(kv, "=", 2)[0]]; !overridden {
+				env = append(env, kv)
+			}
+		}
+	}
+	keys := make([]string, 0, len(procEnv))
+	for k := range procEnv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	set := make([]string, 0, len(keys))
+	for _, k := range keys {
+		set = append(set, k+"="+procEnv[k])
+	}
+	return append(env, set...), set, nil
+}
+
+// Reload will start a new instance of the process while the current one keeps running. Both
+// share the same listening sockets, so no connection is refused during the handoff.
+// Returns a tuple with the previous process, that the caller must stop, and an error in case there's any.
+func (proc *Proc) Reload() (*os.Process, error) {
+	previous := proc.process
+	if err := proc.Start(); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// ForceStop will forcefully send a SIGKILL signal to process, and its children, killing it instantly.
 // Returns an error in case there's any.
 func (proc *Proc) ForceStop() error {
 	if proc.process != nil {
-		err := proc.process.Signal(syscall.SIGKILL)
+		err := SignalGroup(proc.Pid, syscall.SIGKILL)
 		proc.Status.SetStatus("stopped")
 		proc.release()
 		return err
@@ -101,13 +306,17 @@ func (proc *Proc) ForceStop() error {
 	return errors.New("Process does not exist.")
 }
 
-// GracefullyStop will send a SIGTERM signal asking the process to terminate.
+// GracefullyStop will send the StopSignal, SIGTERM by default, asking the process and its children to terminate.
 // The process may choose to die gracefully or ignore this signal completely. In that case
 // the process will keep running unless you call ForceStop()
 // Returns an error in case there's any.
 func (proc *Proc) GracefullyStop() error {
 	if proc.process != nil {
-		err := proc.process.Signal(syscall.SIGTERM)
+		signal, err := ParseSignal(proc.StopSignal)
+		if err != nil {
+			return err
+		}
+		err = SignalGroup(proc.Pid, signal)
 		proc.Status.SetStatus("asked to stop")
 		return err
 	}
@@ -126,10 +335,36 @@ func (proc *Proc) Restart() error {
 	return proc.Start()
 }
 
+// Flush will truncate the out and err files of the process, whether it is running or not.
+// Returns an error in case there's any.
+func (proc *Proc) Flush() error {
+	if err := flushLog(proc.outLog, proc.Outfile); err != nil {
+		return err
+	}
+	return flushLog(proc.errLog, proc.Errfile)
+}
+
+func flushLog(writer *logrotate.Writer, filename string) error {
+	// the writer is closed once the process is gone, truncate the file directly then
+	if writer != nil && writer.Truncate() == nil {
+		return nil
+	}
+	return os.Truncate(filename, 0)
+}
+
 // Delete will delete everything created by this process, including the out, err and pid file.
+// The folder of a cluster instance is shared with the other instances, so only its own files are deleted.
 // Returns an error in case there's any.
 func (proc *Proc) Delete() error {
 	proc.release()
+	proc.closeSockets()
+	// children that left the process group are still on the cgroup, which can't be removed with them
+	if err := cgroup.Kill(proc.Name); err != nil && err != cgroup.ErrDisabled {
+		log.Warnf("Failed to kill the cgroup of proc %s due to %s", proc.Name, err)
+	}
+	if err := cgroup.Remove(proc.Name); err != nil && err != cgroup.ErrDisabled {
+		log.Warnf("Failed to remove the cgroup of proc %s due to %s", proc.Name, err)
+	}
 	err := utils.DeleteFile(proc.Outfile)
 	if err != nil {
 		return err
@@ -138,9 +373,59 @@ func (proc *Proc) Delete() error {
 	if err != nil {
 		return err
 	}
+	if proc.Cluster != "" {
+		logrotate.Remove(proc.Outfile)
+		logrotate.Remove(proc.Errfile)
+		return nil
+	}
 	return os.RemoveAll(proc.Path)
 }
 
+// Clone will return a new, not started, instance of the cluster of proc with the same settings.
+// Slices and maps are copied, so changing the settings of an instance leaves the others untouched.
+func (proc *Proc) Clone(instance int) ProcContainer {
+	clone := *proc
+	clone.Args = copyStrings(proc.Args)
+	clone.Env = copyEnv(proc.Env)
+	clone.EnvFiles = copyStrings(proc.EnvFiles)
+	clone.Sockets = copyStrings(proc.Sockets)
+	clone.SourceWatch.Ignore = copyStrings(proc.SourceWatch.Ignore)
+	clone.Build.Tags = copyStrings(proc.Build.Tags)
+	clone.Build.Env = copyEnv(proc.Build.Env)
+	clone.Groups = copyStrings(proc.Groups)
+	clone.Name = InstanceName(proc.Cluster, instance)
+	clone.InstanceID = instance
+	clone.Pidfile = InstanceFile(proc.Path, proc.Cluster, instance, ".pid")
+	clone.Outfile = InstanceFile(proc.Path, proc.Cluster, instance, ".out")
+	clone.Errfile = InstanceFile(proc.Path, proc.Cluster, instance, ".err")
+	clone.Pid = 0
+	clone.Status = &ProcStatus{}
+	clone.process = nil
+	clone.outLog = nil
+	clone.errLog = nil
+	clone.sockets = nil
+	clone.environ = nil
+	return &clone
+}
+
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
+
+func copyEnv(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(env))
+	for k, v := range env {
+		copied[k] = v
+	}
+	return copied
+}
+
 // IsAlive will check if the process is alive or not.
 // Returns true if the process is alive or false otherwise.
 func (proc *Proc) IsAlive() bool {
@@ -152,16 +437,29 @@ func (proc *Proc) IsAlive() bool {
 }
 
 // Watch will stop execution and wait until the process change its state. Usually changing state, means that the process died.
+// The children it left behind are killed then.
 // Returns a tuple with the new process state and an error in case there's any.
 func (proc *Proc) Watch() (*os.ProcessState, error) {
-	return proc.process.Wait()
+	// proc.process is replaced by Reload while the previous instance is still watched
+	process := proc.process
+	pid := process.Pid
+	state, err := process.Wait()
+	killGroup(pid)
+	return state, err
 }
 
-// Will release the process and remove its PID file
-func (proc *Proc) release() {
-	if proc.process != nil {
-		proc.process.Release()
+// GetChildren will return the process tree under the running process.
+// Returns a tuple with the children and an error in case there's any.
+func (proc *Proc) GetChildren() ([]*ProcessNode, error) {
+	if !proc.IsAlive() {
+		return nil, nil
 	}
+	return ProcessTree(proc.Pid)
+}
+
+// Will remove the PID file of the process. The process itself is not released, its watcher
+// waits on it, which frees it, and Release would change its Pid while the watcher reads it.
+func (proc *Proc) release() {
 	utils.DeleteFile(proc.Pidfile)
 }
 
@@ -170,9 +468,9 @@ func (proc *Proc) NotifyStopped() {
 	proc.Pid = -1
 }
 
-// AddRestart is add one restart to proc status
-func (proc *Proc) AddRestart() {
-	proc.Status.AddRestart()
+// AddRestart is add one restart to proc status, with the reason it was restarted
+func (proc *Proc) AddRestart(reason string) {
+	proc.Status.AddRestart(reason)
 }
 
 // GetPid will return proc current PID
@@ -190,6 +488,11 @@ func (proc *Proc) GetErrFile() string {
 	return proc.Errfile
 }
 
+// GetLogWriters will return the writers of the out and err files, nil until the proc is started
+func (proc *Proc) GetLogWriters() (*logrotate.Writer, *logrotate.Writer) {
+	return proc.outLog, proc.errLog
+}
+
 // GetPidFile will return proc pid file
 func (proc *Proc) GetPidFile() string {
 	return proc.Pidfile
@@ -231,7 +534,80 @@ func (proc *Proc) ResetUpTime() {
 
 // SetSysInfo will get current proc cpu and memory usage
 func (proc *Proc) SetSysInfo() {
-	proc.Status.SetSysInfo(proc.process.Pid)
+	if proc.process == nil || proc.Pid <= 0 {
+		// Never started on this daemon, such as a resurrected stopped proc.
+		proc.Status.ResetSysInfo()
+		return
+	}
+	proc.Status.SetSysInfo(proc.Pid)
+}
+
+// SetExitCode will record the exit code of the last run
+func (proc *Proc) SetExitCode(code int) {
+	proc.Status.SetExitCode(code)
+}
+
+// SetHealth will record the outcome of the latest health probes
+func (proc *Proc) SetHealth(result health.Result) {
+	proc.Status.SetHealth(result)
+}
+
+// GetHealthCheck will return the health probe of the process
+func (proc *Proc) GetHealthCheck() health.Check {
+	return proc.HealthCheck
+}
+
+// GetMaxMemory will return the memory limit of the process and the samples in a row above it before a restart
+func (proc *Proc) GetMaxMemory() (int64, int) {
+	return proc.MaxMemory, proc.MaxMemorySamples
+}
+
+// GetCronRestart will return the cron expression the process is restarted on
+func (proc *Proc) GetCronRestart() string {
+	return proc.CronRestart
+}
+
+// GetCgroup will return the hard resource limits of the process
+func (proc *Proc) GetCgroup() cgroup.Limits {
+	return proc.Cgroup
+}
+
+// GetCredential will return the user and groups the process runs as, empty for the daemon user
+func (proc *Proc) GetCredential() string {
+	return FormatCredential(proc.User, proc.Group, proc.Groups)
+}
+
+// GetSourcePath will return the source directory the binary was built from
+func (proc *Proc) GetSourcePath() string {
+	return proc.SourcePath
+}
+
+// GetBuild will return how the source of the process is compiled
+func (proc *Proc) GetBuild() build.Spec {
+	return proc.Build
+}
+
+// GetKeepBuilds will return the number of builds kept for rollbacks
+func (proc *Proc) GetKeepBuilds() int {
+	return proc.KeepBuilds
+}
+
+// GetRuntime will return the runtime of the process and its interpreter, if any
+func (proc *Proc) GetRuntime() (string, string) {
+	if proc.Runtime == "" {
+		return RuntimeGo, ""
+	}
+	return proc.Runtime, proc.Interpreter
+}
+
+// GetCopy will return true if the exec runtime binary was copied into the process folder
+func (proc *Proc) GetCopy() bool {
+	return proc.Copy
+}
+
+// GetSourceWatch will return how the source of the process is watched
+func (proc *Proc) GetSourceWatch() sourcewatch.Config {
+	return proc.SourceWatch
 }
 
 // Identifier is that will be used by watcher to keep track of its processes
@@ -248,3 +624,76 @@ func (proc *Proc) ShouldKeepAlive() bool {
 func (proc *Proc) GetName() string {
 	return proc.Name
 }
+
+// GetEnv will return the variables pmgo sets on the process: the env files, read in order,
+// overridden by Env. Env files are read again on every call so changes are picked up on restart,
+// and their variables are never saved.
+// Returns a tuple with the variables and an error, failing the start, when an env file can't be read.
+func (proc *Proc) GetEnv() (map[string]string, error) {
+	env := make(map[string]string)
+	for _, envFile := range proc.EnvFiles {
+		fileEnv, err := utils.ReadEnvFile(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read env file %s of proc %s: %s", envFile, proc.Name, err)
+		}
+		for k, v := range fileEnv {
+			env[k] = v
+		}
+	}
+	for k, v := range proc.Env {
+		env[k] = v
+	}
+	return env, nil
+}
+
+// GetEnviron will return the variables pmgo set on the process when it was last started, without
+// the daemon ones, or nil if it was not started by this daemon yet.
+func (proc *Proc) GetEnviron() []string {
+	return proc.environ
+}
+
+// GetRestartPolicy will return how the proc is restarted when it dies
+func (proc *Proc) GetRestartPolicy() RestartPolicy {
+	return proc.RestartPolicy
+}
+
+// GetStopSignal will return the signal sent to gracefully stop the proc
+func (proc *Proc) GetStopSignal() string {
+	if proc.StopSignal == "" {
+		return "SIGTERM"
+	}
+	return proc.StopSignal
+}
+
+// GetKillTimeout will return how long the proc may take to stop before it is killed
+func (proc *Proc) GetKillTimeout() time.Duration {
+	if proc.KillTimeout <= 0 {
+		return DefaultKillTimeout
+	}
+	return proc.KillTimeout
+}
+
+// GetSockets will return the sockets pmgo listens on for the proc
+func (proc *Proc) GetSockets() []string {
+	return proc.Sockets
+}
+
+// GetCluster will return the name of the cluster the proc belongs to, empty if none
+func (proc *Proc) GetCluster() string {
+	return proc.Cluster
+}
+
+// GetInstanceID will return the proc index on its cluster
+func (proc *Proc) GetInstanceID() int {
+	return proc.InstanceID
+}
+
+// GetCwd will return the proc working directory
+func (proc *Proc) GetCwd() string {
+	return proc.Cwd
+}
+
+// GetCleanEnv will return true if the process does not inherit the daemon environment
+func (proc *Proc) GetCleanEnv() bool {
+	return proc.CleanEnv
+}
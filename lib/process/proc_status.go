@@ -5,6 +5,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/struCoder/pidusage"
+	"github.com/struCoder/pmgo/lib/health"
 	"github.com/struCoder/pmgo/lib/utils"
 )
 
@@ -15,6 +16,25 @@ type ProcStatus struct {
 	StartTime int64
 	Uptime    string
 	Sys       *pidusage.SysInfo
+	ExitCode  int            // ExitCode is the exit code of the last run, -1 when it was killed by a signal.
+	Health    health.Result  // Health is the outcome of the latest health probes.
+	History   []RestartEvent // History are the latest restarts, the most recent last.
+}
+
+// RestartEvent is a restart of a process and the reason it was restarted.
+type RestartEvent struct {
+	Time   int64  // Time is the unix time of the restart.
+	Reason string // Reason is why the process was restarted.
+}
+
+// maxRestartHistory is the number of restarts kept on the history.
+const maxRestartHistory = 10
+
+// Copy will return a copy of the status, that can be read once the master lock is released.
+func (proc_status *ProcStatus) Copy() *ProcStatus {
+	status := *proc_status
+	status.History = append([]RestartEvent(nil), proc_status.History...)
+	return &status
 }
 
 // SetStatus will set the process string status.
@@ -22,9 +42,23 @@ func (proc_status *ProcStatus) SetStatus(status string) {
 	proc_status.Status = status
 }
 
-// AddRestart will add one restart to the process status.
-func (proc_status *ProcStatus) AddRestart() {
+// AddRestart will add one restart to the process status and record its reason on the history.
+func (proc_status *ProcStatus) AddRestart(reason string) {
 	proc_status.Restarts++
+	proc_status.History = append(proc_status.History, RestartEvent{Time: time.Now().Unix(), Reason: reason})
+	if len(proc_status.History) > maxRestartHistory {
+		proc_status.History = proc_status.History[len(proc_status.History)-maxRestartHistory:]
+	}
+}
+
+// SetExitCode will record the exit code of the last run.
+func (proc_status *ProcStatus) SetExitCode(code int) {
+	proc_status.ExitCode = code
+}
+
+// SetHealth will record the outcome of the latest health probes.
+func (proc_status *ProcStatus) SetHealth(result health.Result) {
+	proc_status.Health = result
 }
 
 // InitUptime will record proc start time
@@ -50,3 +84,8 @@ func (proc_status *ProcStatus) SetSysInfo(pid int) {
 		log.Error(err)
 	}
 }
+
+// ResetSysInfo will set cpu and memory usage to 0
+func (proc_status *ProcStatus) ResetSysInfo() {
+	proc_status.Sys = &pidusage.SysInfo{}
+}
@@ -0,0 +1,139 @@
+package process
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/rand"
+	"time"
+)
+
+// DefaultRestartPolicy is used for every RestartPolicy field left unset.
+var DefaultRestartPolicy = RestartPolicy{
+	Delay:       time.Second,
+	MaxDelay:    time.Minute,
+	Jitter:      Float(0.1),
+	MinUptime:   10 * time.Second,
+	MaxRestarts: Int(15),
+	Window:      5 * time.Minute,
+}
+
+// RestartPolicy describes how a dead process with KeepAlive set is restarted.
+type RestartPolicy struct {
+	Delay       time.Duration // Delay is the wait before the first restart. It doubles on each unstable run.
+	MaxDelay    time.Duration // MaxDelay is the maximum wait between restarts.
+	Jitter      *float64      // Jitter is the fraction of the delay randomly added or removed from it. 0 disables it.
+	MinUptime   time.Duration // MinUptime is how long a run must last to be considered stable and reset the backoff.
+	MaxRestarts *int          // MaxRestarts is the number of restarts allowed within Window before the proc is errored. 0 allows none, a negative value is unlimited.
+	Window      time.Duration // Window is the period MaxRestarts is counted on.
+}
+
+// Float will return a pointer to value, for the optional RestartPolicy fields.
+func Float(value float64) *float64 {
+	return &value
+}
+
+// Int will return a pointer to value, for the optional RestartPolicy fields.
+func Int(value int) *int {
+	return &value
+}
+
+// WithDefaults will return the policy with every unset field taken from DefaultRestartPolicy.
+func (policy RestartPolicy) WithDefaults() RestartPolicy {
+	if policy.Delay == 0 {
+		policy.Delay = DefaultRestartPolicy.Delay
+	}
+	if policy.MaxDelay == 0 {
+		policy.MaxDelay = DefaultRestartPolicy.MaxDelay
+	}
+	if policy.Jitter == nil {
+		policy.Jitter = Float(*DefaultRestartPolicy.Jitter)
+	}
+	if policy.MinUptime == 0 {
+		policy.MinUptime = DefaultRestartPolicy.MinUptime
+	}
+	if policy.MaxRestarts == nil {
+		policy.MaxRestarts = Int(*DefaultRestartPolicy.MaxRestarts)
+	}
+	if policy.Window == 0 {
+		policy.Window = DefaultRestartPolicy.Window
+	}
+	return policy
+}
+
+// RestartsAllowed will return the number of restarts allowed within Window, or -1 when unlimited.
+func (policy RestartPolicy) RestartsAllowed() int {
+	if policy.MaxRestarts == nil || *policy.MaxRestarts < 0 {
+		return -1
+	}
+	return *policy.MaxRestarts
+}
+
+// gobRestartPolicy is how RestartPolicy is sent over net/rpc. gob does not send pointers
+// to zero values, so whether the optional fields are set is sent on its own.
+type gobRestartPolicy struct {
+	Delay          time.Duration
+	MaxDelay       time.Duration
+	Jitter         float64
+	JitterSet      bool
+	MinUptime      time.Duration
+	MaxRestarts    int
+	MaxRestartsSet bool
+	Window         time.Duration
+}
+
+// GobEncode will encode the policy, keeping the optional fields set to 0.
+func (policy RestartPolicy) GobEncode() ([]byte, error) {
+	encoded := gobRestartPolicy{
+		Delay:     policy.Delay,
+		MaxDelay:  policy.MaxDelay,
+		MinUptime: policy.MinUptime,
+		Window:    policy.Window,
+	}
+	if policy.Jitter != nil {
+		encoded.Jitter = *policy.Jitter
+		encoded.JitterSet = true
+	}
+	if policy.MaxRestarts != nil {
+		encoded.MaxRestarts = *policy.MaxRestarts
+		encoded.MaxRestartsSet = true
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&encoded)
+	return buf.Bytes(), err
+}
+
+// GobDecode will decode a policy encoded by GobEncode.
+func (policy *RestartPolicy) GobDecode(data []byte) error {
+	var decoded gobRestartPolicy
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded); err != nil {
+		return err
+	}
+	*policy = RestartPolicy{
+		Delay:     decoded.Delay,
+		MaxDelay:  decoded.MaxDelay,
+		MinUptime: decoded.MinUptime,
+		Window:    decoded.Window,
+	}
+	if decoded.JitterSet {
+		policy.Jitter = Float(decoded.Jitter)
+	}
+	if decoded.MaxRestartsSet {
+		policy.MaxRestarts = Int(decoded.MaxRestarts)
+	}
+	return nil
+}
+
+// Backoff will return how long to wait before restarting a proc that had failures unstable runs in a row.
+func (policy RestartPolicy) Backoff(failures int) time.Duration {
+	delay := policy.Delay
+	for i := 1; i < failures && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if policy.Jitter != nil && *policy.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * *policy.Jitter * float64(delay))
+	}
+	return delay
+}
@@ -0,0 +1,135 @@
+package process
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RestartPolicy
+		failures int
+		min      time.Duration
+		max      time.Duration
+	}{
+		{
+			name:     "first failure",
+			policy:   RestartPolicy{Delay: time.Second, MaxDelay: time.Minute},
+			failures: 1,
+			min:      time.Second,
+			max:      time.Second,
+		},
+		{
+			name:     "no failures",
+			policy:   RestartPolicy{Delay: time.Second, MaxDelay: time.Minute},
+			failures: 0,
+			min:      time.Second,
+			max:      time.Second,
+		},
+		{
+			name:     "doubles",
+			policy:   RestartPolicy{Delay: time.Second, MaxDelay: time.Minute},
+			failures: 4,
+			min:      8 * time.Second,
+			max:      8 * time.Second,
+		},
+		{
+			name:     "capped",
+			policy:   RestartPolicy{Delay: time.Second, MaxDelay: 10 * time.Second},
+			failures: 5,
+			min:      10 * time.Second,
+			max:      10 * time.Second,
+		},
+		{
+			name:     "delay above max delay",
+			policy:   RestartPolicy{Delay: time.Minute, MaxDelay: 10 * time.Second},
+			failures: 1,
+			min:      10 * time.Second,
+			max:      10 * time.Second,
+		},
+		{
+			name:     "many failures",
+			policy:   RestartPolicy{Delay: time.Second, MaxDelay: time.Minute},
+			failures: 1000,
+			min:      time.Minute,
+			max:      time.Minute,
+		},
+		{
+			name:     "no jitter",
+			policy:   RestartPolicy{Delay: time.Second, MaxDelay: time.Minute, Jitter: Float(0)},
+			failures: 2,
+			min:      2 * time.Second,
+			max:      2 * time.Second,
+		},
+		{
+			name:     "jitter",
+			policy:   RestartPolicy{Delay: time.Second, MaxDelay: time.Minute, Jitter: Float(0.5)},
+			failures: 3,
+			min:      2 * time.Second,
+			max:      6 * time.Second,
+		},
+		{
+			name:     "defaults",
+			policy:   RestartPolicy{}.WithDefaults(),
+			failures: 1,
+			min:      900 * time.Millisecond,
+			max:      1100 * time.Millisecond,
+		},
+	}
+	for _, test := range tests {
+		// jitter is random, so every case is tried a few times
+		for i := 0; i < 100; i++ {
+			if got := test.policy.Backoff(test.failures); got < test.min || got > test.max {
+				t.Errorf("%s: Backoff(%d) = %s, want between %s and %s", test.name, test.failures, got, test.min, test.max)
+				break
+			}
+		}
+	}
+}
+
+func TestRestartsAllowed(t *testing.T) {
+	tests := []struct {
+		restarts int // restarts is the max restarts as given by users.
+		want     int
+	}{
+		{restarts: 0, want: 0},
+		{restarts: 1, want: 1},
+		{restarts: 15, want: 15},
+		{restarts: -1, want: -1},
+		{restarts: -5, want: -1},
+	}
+	for _, test := range tests {
+		policy := RestartPolicy{MaxRestarts: Int(test.restarts)}.WithDefaults()
+		if got := policy.RestartsAllowed(); got != test.want {
+			t.Errorf("RestartsAllowed() with max restarts %d = %d, want %d", test.restarts, got, test.want)
+		}
+	}
+	if got := (RestartPolicy{}).WithDefaults().RestartsAllowed(); got != *DefaultRestartPolicy.MaxRestarts {
+		t.Errorf("RestartsAllowed() by default = %d, want %d", got, *DefaultRestartPolicy.MaxRestarts)
+	}
+}
+
+func TestRestartPolicyGob(t *testing.T) {
+	tests := []RestartPolicy{
+		{},
+		{Delay: time.Second, Jitter: Float(0), MaxRestarts: Int(0)},
+		{Window: time.Minute, Jitter: Float(0.5), MaxRestarts: Int(-1)},
+	}
+	for _, policy := range tests {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&Proc{RestartPolicy: policy}); err != nil {
+			t.Fatal(err)
+		}
+		var proc Proc
+		if err := gob.NewDecoder(&buf).Decode(&proc); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(proc.RestartPolicy, policy) {
+			t.Errorf("got policy %+v after gob, want %+v", proc.RestartPolicy, policy)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package process
+
+import "path/filepath"
+
+// Runtimes a proc is started with.
+const (
+	// RuntimeGo builds the binary from the go source on SourcePath. It is the default.
+	RuntimeGo = "go"
+	// RuntimeExec runs the binary on SourcePath, in place or copied into the proc folder.
+	RuntimeExec = "exec"
+	// RuntimeInterpreter runs the script on SourcePath through an interpreter, such as python3.
+	RuntimeInterpreter = "interpreter"
+)
+
+// command will return the program started for the process and its arguments, the process
+// name first.
+func (proc *Proc) command() (string, []string) {
+	if proc.Runtime == RuntimeInterpreter {
+		return proc.Interpreter, append([]string{proc.Name, proc.Cmd}, proc.Args...)
+	}
+	return proc.Cmd, append([]string{proc.Name}, proc.Args...)
+}
+
+// ownsCmd will return true if Cmd lives on the proc folder, where it was built or copied to,
+// instead of being a file of the user.
+func (proc *Proc) ownsCmd() bool {
+	return filepath.Dir(proc.Cmd) == filepath.Clean(proc.Path)
+}
@@ -0,0 +1,44 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultKillTimeout is how long a process is given to stop after its stop signal
+// before it is killed, when KillTimeout is not set.
+const DefaultKillTimeout = 10 * time.Second
+
+var signals = map[string]syscall.Signal{
+	"SIGTERM":  syscall.SIGTERM,
+	"SIGINT":   syscall.SIGINT,
+	"SIGQUIT":  syscall.SIGQUIT,
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGKILL":  syscall.SIGKILL,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGWINCH": syscall.SIGWINCH,
+}
+
+// ParseSignal will parse a signal name such as SIGINT, INT or a signal number.
+// An empty name is SIGTERM.
+// Returns a tuple with the signal and an error in case there's any.
+func ParseSignal(name string) (syscall.Signal, error) {
+	if name == "" {
+		return syscall.SIGTERM, nil
+	}
+	if number, err := strconv.Atoi(name); err == nil && number > 0 {
+		return syscall.Signal(number), nil
+	}
+	upper := strings.ToUpper(name)
+	if !strings.HasPrefix(upper, "SIG") {
+		upper = "SIG" + upper
+	}
+	if signal, ok := signals[upper]; ok {
+		return signal, nil
+	}
+	return 0, fmt.Errorf("unknown signal %s", name)
+}
@@ -0,0 +1,45 @@
+package process
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		name string
+		want syscall.Signal
+		err  bool
+	}{
+		{name: "", want: syscall.SIGTERM},
+		{name: "SIGINT", want: syscall.SIGINT},
+		{name: "INT", want: syscall.SIGINT},
+		{name: "sigquit", want: syscall.SIGQUIT},
+		{name: "hup", want: syscall.SIGHUP},
+		{name: "SIGKILL", want: syscall.SIGKILL},
+		{name: "USR2", want: syscall.SIGUSR2},
+		{name: "SIGWINCH", want: syscall.SIGWINCH},
+		{name: "15", want: syscall.SIGTERM},
+		{name: "9", want: syscall.SIGKILL},
+		{name: "0", err: true},
+		{name: "-1", err: true},
+		{name: "SIGFOO", err: true},
+		{name: "SIG", err: true},
+	}
+	for _, test := range tests {
+		got, err := ParseSignal(test.name)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseSignal(%q) = %s, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSignal(%q) returned %s", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseSignal(%q) = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
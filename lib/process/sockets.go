@@ -0,0 +1,134 @@
+package process
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// socketWrapper exports LISTEN_PID with the pid of the shell and replaces it with the process command,
+// so the process gets the same pid and can check LISTEN_PID the same way it does under systemd.
+// Go can't set a variable to the pid of a child before it runs, hence the shell: procs with sockets
+// need /bin/sh, and their argv[0] is the full command path rather than the proc name.
+const socketWrapper = `LISTEN_PID=$$; export LISTEN_PID; exec "$0" "$@"`
+
+// sharedSocket is a listening socket file and the number of procs using it. Cluster instances
+// share the same sockets, so they are only closed when no instance uses them anymore.
+type sharedSocket struct {
+	file *os.File
+	refs int
+}
+
+var (
+	socketsMutex sync.Mutex
+	sockets      = make(map[string]*sharedSocket)
+)
+
+// parseSocket will split a socket declared as [name=]network://address, where network is tcp
+// or unix, or as a plain tcp host:port.
+// Returns the socket name, network and address.
+func parseSocket(index int, socket string) (string, string, string) {
+	name := "listen" + strconv.Itoa(index)
+	if idx := strings.Index(socket, "="); idx > 0 {
+		name = socket[:idx]
+		socket = socket[idx+1:]
+	}
+	network := "tcp"
+	if idx := strings.Index(socket, "://"); idx > 0 {
+		network = socket[:idx]
+		socket = socket[idx+3:]
+	}
+	return name, network, socket
+}
+
+// listenFile will start listening on address and return the listening socket file.
+// Returns a tuple with the file and an error in case there's any.
+func listenFile(network string, address string) (*os.File, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		listener, err := net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		defer listener.Close()
+		return listener.(*net.TCPListener).File()
+	case "unix":
+		listener, err := net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		unixListener := listener.(*net.UnixListener)
+		// the socket file must outlive this listener, it is owned by the returned file
+		unixListener.SetUnlinkOnClose(false)
+		defer unixListener.Close()
+		return unixListener.File()
+	}
+	return nil, errors.New("unsupported socket network " + network)
+}
+
+// openSockets will start listening on every socket of the process not listened yet. The sockets
+// are kept open across restarts and reloads, so no connection is refused meanwhile.
+// Returns an error in case there's any.
+func (proc *Proc) openSockets() error {
+	socketsMutex.Lock()
+	defer socketsMutex.Unlock()
+	for index := len(proc.sockets); index < len(proc.Sockets); index++ {
+		_, network, address := parseSocket(index, proc.Sockets[index])
+		key := network + "://" + address
+		socket, ok := sockets[key]
+		if !ok {
+			file, err := listenFile(network, address)
+			if err != nil {
+				return err
+			}
+			socket = &sharedSocket{file: file}
+			sockets[key] = socket
+		}
+		socket.refs++
+		proc.sockets = append(proc.sockets, socket.file)
+	}
+	return nil
+}
+
+// closeSockets will stop listening on every socket of the process no other proc uses.
+func (proc *Proc) closeSockets() {
+	socketsMutex.Lock()
+	defer socketsMutex.Unlock()
+	for index := range proc.sockets {
+		_, network, address := parseSocket(index, proc.Sockets[index])
+		key := network + "://" + address
+		socket, ok := sockets[key]
+		if !ok {
+			continue
+		}
+		socket.refs--
+		if socket.refs > 0 {
+			continue
+		}
+		socket.file.Close()
+		delete(sockets, key)
+		if network == "unix" {
+			os.Remove(address)
+		}
+	}
+	proc.sockets = nil
+}
+
+// socketEnv will return the systemd socket activation variables describing the inherited sockets.
+func (proc *Proc) socketEnv() map[string]string {
+	if len(proc.Sockets) == 0 {
+		return nil
+	}
+	names := []string{}
+	for index, socket := range proc.Sockets {
+		name, _, _ := parseSocket(index, socket)
+		names = append(names, name)
+	}
+	return map[string]string{
+		"LISTEN_FDS":     strconv.Itoa(len(proc.Sockets)),
+		"LISTEN_FDNAMES": strings.Join(names, ":"),
+	}
+}
@@ -0,0 +1,29 @@
+package process
+
+import "testing"
+
+func TestParseSocket(t *testing.T) {
+	tests := []struct {
+		index   int
+		socket  string
+		name    string
+		network string
+		address string
+	}{
+		{index: 0, socket: ":8080", name: "listen0", network: "tcp", address: ":8080"},
+		{index: 2, socket: "127.0.0.1:8080", name: "listen2", network: "tcp", address: "127.0.0.1:8080"},
+		{index: 0, socket: "tcp://:8080", name: "listen0", network: "tcp", address: ":8080"},
+		{index: 0, socket: "tcp6://[::1]:8080", name: "listen0", network: "tcp6", address: "[::1]:8080"},
+		{index: 1, socket: "unix:///run/app.sock", name: "listen1", network: "unix", address: "/run/app.sock"},
+		{index: 0, socket: "http=:8080", name: "http", network: "tcp", address: ":8080"},
+		{index: 0, socket: "admin=unix:///run/admin.sock", name: "admin", network: "unix", address: "/run/admin.sock"},
+		{index: 3, socket: "=:8080", name: "listen3", network: "tcp", address: "=:8080"},
+	}
+	for _, test := range tests {
+		name, network, address := parseSocket(test.index, test.socket)
+		if name != test.name || network != test.network || address != test.address {
+			t.Errorf("parseSocket(%d, %q) = %q, %q, %q, want %q, %q, %q", test.index, test.socket,
+				name, network, address, test.name, test.network, test.address)
+		}
+	}
+}
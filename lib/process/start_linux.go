@@ -0,0 +1,47 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"os"
+
+	"github.com/struCoder/pmgo/lib/cgroup"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// startProcess will start the process of proc directly inside its cgroup, so whatever it forks is
+// accounted and limited from the start. Every proc gets its own cgroup when the daemon has one, so
+// its usage can be read even without limits.
+// Returns a tuple with the process and an error in case there's any.
+func (proc *Proc) startProcess(cmd string, args []string, procAtr *os.ProcAttr) (*os.Process, error) {
+	err := cgroup.Prepare(proc.Name, proc.Cgroup)
+	if err == cgroup.ErrDisabled {
+		return os.StartProcess(cmd, args, procAtr)
+	}
+	if err != nil && proc.Cgroup.Enabled() {
+		log.Warnf("Proc %s runs without its cgroup limits due to %s", proc.Name, err)
+	}
+	dir, err := cgroup.Open(proc.Name)
+	if err != nil {
+		return os.StartProcess(cmd, args, procAtr)
+	}
+	defer dir.Close()
+	procAtr.Sys.UseCgroupFD = true
+	procAtr.Sys.CgroupFD = int(dir.Fd())
+	process, err := os.StartProcess(cmd, args, procAtr)
+	procAtr.Sys.UseCgroupFD = false
+	if err == nil {
+		return process, nil
+	}
+	// kernels before 5.7 can't start a process inside a cgroup, it is moved there right after
+	process, err = os.StartProcess(cmd, args, procAtr)
+	if err != nil {
+		return nil, err
+	}
+	if err := cgroup.Move(proc.Name, process.Pid); err != nil && proc.Cgroup.Enabled() {
+		log.Warnf("Proc %s runs without its cgroup limits due to %s", proc.Name, err)
+	}
+	return process, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import "os"
+
+// startProcess will start the process of proc. Cgroups are only supported on linux.
+// Returns a tuple with the process and an error in case there's any.
+func (proc *Proc) startProcess(cmd string, args []string, procAtr *os.ProcAttr) (*os.Process, error) {
+	return os.StartProcess(cmd, args, procAtr)
+}
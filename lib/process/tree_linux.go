@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProcessTree will return the processes descending from pid, reading /proc.
+// Returns a tuple with the children of pid and an error in case there's any.
+func ProcessTree(pid int) ([]*ProcessNode, error) {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int][]*ProcessNode)
+	for _, stat := range stats {
+		content, err := ioutil.ReadFile(stat)
+		if err != nil {
+			// the process exited meanwhile
+			continue
+		}
+		// pid (comm) state ppid ..., where comm may contain spaces and parenthesis
+		line := string(content)
+		end := strings.LastIndex(line, ")")
+		start := strings.Index(line, "(")
+		if start < 0 || end < start {
+			continue
+		}
+		fields := strings.Fields(line[end+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		childPid, _ := strconv.Atoi(strings.TrimSpace(line[:start]))
+		parentPid, _ := strconv.Atoi(fields[1])
+		node := &ProcessNode{Pid: childPid, Command: command(filepath.Dir(stat), line[start+1:end])}
+		children[parentPid] = append(children[parentPid], node)
+	}
+	return buildTree(children, pid), nil
+}
+
+func buildTree(children map[int][]*ProcessNode, pid int) []*ProcessNode {
+	nodes := children[pid]
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Pid < nodes[j].Pid })
+	for _, node := range nodes {
+		node.Children = buildTree(children, node.Pid)
+	}
+	return nodes
+}
+
+// command will return the command line of the process on procDir, or comm for kernel threads
+// and processes that can't be read.
+func command(procDir string, comm string) string {
+	cmdline, err := ioutil.ReadFile(filepath.Join(procDir, "cmdline"))
+	if err != nil || len(cmdline) == 0 {
+		return comm
+	}
+	return strings.TrimSpace(strings.Replace(string(cmdline), "\x00", " ", -1))
+}
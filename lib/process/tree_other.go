@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import "errors"
+
+// ProcessTree is only supported on linux.
+func ProcessTree(pid int) ([]*ProcessNode, error) {
+	return nil, errors.New("process tree is not supported on this platform")
+}
@@ -0,0 +1,171 @@
+/*
+Sourcewatch package watches the source tree of a Go application for changes, so it can be
+rebuilt and restarted during development:
+
+- pmgo start ./svc svc --watch
+
+Only .go files, go.mod and go.sum are considered. Bursts of changes, such as a git checkout,
+are reported once after the debounce delay.
+*/
+package sourcewatch
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultDebounce is the delay used when Config does not set one.
+const DefaultDebounce = 500 * time.Millisecond
+
+// DefaultIgnore are the globs always ignored.
+var DefaultIgnore = []string{".git", "vendor", "node_modules"}
+
+// Config is how the source tree of a process is watched.
+type Config struct {
+	Enabled  bool          // Enabled will rebuild and restart the process when its source changes.
+	Ignore   []string      // Ignore are globs matched against the base name and the path relative to the source of files and directories.
+	Debounce time.Duration // Debounce is how long the tree must stay unchanged before a rebuild.
+}
+
+// Watcher watches a source tree, calling onChange with the changed files once it settles.
+type Watcher struct {
+	root      string
+	config    Config
+	fsWatcher *fsnotify.Watcher
+	onChange  func(files []string)
+	done      chan bool
+}
+
+// New will start watching the tree under root. onChange is never called concurrently.
+// Returns a tuple with the watcher and an error in case there's any.
+func New(root string, config Config, onChange func(files []string)) (*Watcher, error) {
+	if config.Debounce <= 0 {
+		config.Debounce = DefaultDebounce
+	}
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	watcher := &Watcher{
+		root:      root,
+		config:    config,
+		fsWatcher: fsWatcher,
+		onChange:  onChange,
+		done:      make(chan bool),
+	}
+	if err := watcher.addTree(root); err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+	go watcher.loop()
+	return watcher, nil
+}
+
+// Close will stop watching the tree.
+// Returns an error in case there's any.
+func (watcher *Watcher) Close() error {
+	close(watcher.done)
+	return watcher.fsWatcher.Close()
+}
+
+// addTree will watch dir and every directory under it that is not ignored.
+func (watcher *Watcher) addTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != watcher.root && watcher.isIgnored(path) {
+			return filepath.SkipDir
+		}
+		return watcher.fsWatcher.Add(path)
+	})
+}
+
+func (watcher *Watcher) loop() {
+	changed := make(map[string]bool)
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-watcher.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case event, ok := <-watcher.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !watcher.isIgnored(event.Name) {
+					if err := watcher.addTree(event.Name); err != nil {
+						log.Warnf("Could not watch %s due to %s", event.Name, err)
+					}
+					continue
+				}
+			}
+			if event.Op == fsnotify.Chmod || !watcher.isSource(event.Name) {
+				continue
+			}
+			changed[event.Name] = true
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(watcher.config.Debounce)
+			fire = timer.C
+		case err, ok := <-watcher.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warnf("Error watching %s: %s", watcher.root, err)
+		case <-fire:
+			fire = nil
+			files := []string{}
+			for file := range changed {
+				files = append(files, file)
+			}
+			sort.Strings(files)
+			changed = make(map[string]bool)
+			watcher.onChange(files)
+		}
+	}
+}
+
+// isSource will return true if path is a Go source file that is not ignored.
+func (watcher *Watcher) isSource(path string) bool {
+	base := filepath.Base(path)
+	if !strings.HasSuffix(base, ".go") && base != "go.mod" && base != "go.sum" {
+		return false
+	}
+	return !watcher.isIgnored(path)
+}
+
+// isIgnored will return true if path, or any directory between root and it, matches an ignore glob.
+func (watcher *Watcher) isIgnored(path string) bool {
+	rel, err := filepath.Rel(watcher.root, path)
+	if err != nil {
+		return false
+	}
+	patterns := append(append([]string{}, DefaultIgnore...), watcher.config.Ignore...)
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		for _, part := range parts {
+			if ok, _ := filepath.Match(pattern, part); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
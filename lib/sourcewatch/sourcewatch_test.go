@@ -0,0 +1,33 @@
+package sourcewatch
+
+import "testing"
+
+func TestIsIgnored(t *testing.T) {
+	tests := []struct {
+		path   string
+		ignore []string
+		want   bool
+	}{
+		{path: "/src/main.go", want: false},
+		{path: "/src/cmd/server/main.go", want: false},
+		{path: "/src/.git", want: true},
+		{path: "/src/.git/HEAD", want: true},
+		{path: "/src/vendor/github.com/pkg/errors/errors.go", want: true},
+		{path: "/src/web/node_modules/lib/index.go", want: true},
+		{path: "/src/vendored/main.go", want: false},
+		{path: "/src/testdata/fixture.go", ignore: []string{"testdata"}, want: true},
+		{path: "/src/main_test.go", ignore: []string{"*_test.go"}, want: true},
+		{path: "/src/pkg/util_test.go", ignore: []string{"*_test.go"}, want: true},
+		{path: "/src/main.go", ignore: []string{"*_test.go"}, want: false},
+		{path: "/src/gen/api.go", ignore: []string{"gen/*.go"}, want: true},
+		{path: "/src/pkg/gen/api.go", ignore: []string{"gen/*.go"}, want: false},
+		{path: "/src/internal/mock/db.go", ignore: []string{"mock"}, want: true},
+		{path: "/src/internal/mock/db.go", ignore: []string{"[invalid"}, want: false},
+	}
+	for _, test := range tests {
+		watcher := &Watcher{root: "/src", config: Config{Ignore: test.ignore}}
+		if got := watcher.isIgnored(test.path); got != test.want {
+			t.Errorf("isIgnored(%q) with ignore %v = %v, want %v", test.path, test.ignore, got, test.want)
+		}
+	}
+}
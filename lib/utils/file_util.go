@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -56,3 +59,51 @@ func DeleteFile(filepath string) error {
 	err = os.Remove(filepath)
 	return err
 }
+
+// CopyFile will copy src to the executable file dst, replacing it if it exists.
+// Returns an error in case there's any.
+func CopyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ReadEnvFile will parse a .env file with one KEY=VALUE per line. Blank lines, comments
+// starting with '#' and a leading 'export ' are ignored, surrounding quotes are removed.
+// Returns a tuple with the variables and an error in case there's any.
+func ReadEnvFile(filepath string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	for n, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", filepath, n+1)
+		}
+		key := strings.TrimSpace(line[:idx])
+		value := strings.TrimSpace(line[idx+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	return env, nil
+}
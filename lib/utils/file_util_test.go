@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		err     bool
+	}{
+		{
+			name:    "empty",
+			content: "",
+			want:    map[string]string{},
+		},
+		{
+			name:    "plain",
+			content: "A=1\nB=two words\n",
+			want:    map[string]string{"A": "1", "B": "two words"},
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# comment\n\n  \nA=1\n  # indented comment\n",
+			want:    map[string]string{"A": "1"},
+		},
+		{
+			name:    "export and spaces",
+			content: "export A=1\n B = 2 \n",
+			want:    map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:    "quotes",
+			content: "A=\"a b\"\nB='c d'\nC=\"unbalanced'\nD=\"\"\n",
+			want:    map[string]string{"A": "a b", "B": "c d", "C": "\"unbalanced'", "D": ""},
+		},
+		{
+			name:    "equal sign in value",
+			content: "URL=postgres://host/db?sslmode=disable\n",
+			want:    map[string]string{"URL": "postgres://host/db?sslmode=disable"},
+		},
+		{
+			name:    "empty value",
+			content: "A=\n",
+			want:    map[string]string{"A": ""},
+		},
+		{
+			name:    "last one wins",
+			content: "A=1\nA=2\n",
+			want:    map[string]string{"A": "2"},
+		},
+		{
+			name:    "missing equal sign",
+			content: "A=1\nB\n",
+			err:     true,
+		},
+		{
+			name:    "missing key",
+			content: "=1\n",
+			err:     true,
+		},
+	}
+	dir, err := ioutil.TempDir("", "envfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, test := range tests {
+		filename := filepath.Join(dir, string(rune('a'+i))+".env")
+		t.Run(test.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(filename, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadEnvFile(filename)
+			if test.err {
+				if err == nil {
+					t.Errorf("ReadEnvFile = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadEnvFile returned %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ReadEnvFile = %v, want %v", got, test.want)
+			}
+		})
+	}
+	if _, err := ReadEnvFile(filepath.Join(dir, "missing.env")); err == nil {
+		t.Error("ReadEnvFile of a missing file returned no error")
+	}
+}
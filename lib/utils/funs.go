@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/olekukonko/tablewriter"
 )
@@ -67,6 +69,33 @@ func FormatMemory(input int) string {
 	return strconv.Itoa(input/GB) + "GB"
 }
 
+// ParseSize will parse a human readable size such as 512K, 10M or 1G into bytes.
+// A plain number is taken as bytes.
+// Returns a tuple with the size and an error in case there's any.
+func ParseSize(input string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(input))
+	str = strings.TrimSuffix(str, "B")
+	unit := int64(BYTE)
+	if str != "" {
+		switch str[len(str)-1] {
+		case 'K':
+			unit = KB
+		case 'M':
+			unit = MB
+		case 'G':
+			unit = GB
+		}
+		if unit != BYTE {
+			str = str[:len(str)-1]
+		}
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", input)
+	}
+	return int64(value * float64(unit)), nil
+}
+
 // GetTableWriter will return instance of tablewriter
 func GetTableWriter() *tablewriter.Table {
 	table := tablewriter.NewWriter(os.Stdout)
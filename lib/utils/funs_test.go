@@ -0,0 +1,41 @@
+package utils
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+		err   bool
+	}{
+		{input: "0", want: 0},
+		{input: "512", want: 512},
+		{input: "512B", want: 512},
+		{input: "10K", want: 10 * KB},
+		{input: "10kb", want: 10 * KB},
+		{input: "10M", want: 10 * MB},
+		{input: " 1.5G ", want: 3 * GB / 2},
+		{input: "0.5m", want: MB / 2},
+		{input: "", err: true},
+		{input: "M", err: true},
+		{input: "-1M", err: true},
+		{input: "ten", err: true},
+		{input: "10T", err: true},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.input)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseSize(%q) = %d, want an error", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSize(%q) returned %s", test.input, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", test.input, got, test.want)
+		}
+	}
+}
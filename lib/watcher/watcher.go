@@ -14,6 +14,13 @@ type ProcStatus struct {
 	err   error
 }
 
+// DeadProc is a watched proc that died, with its state when it could be read. The master records
+// its exit code, since the proc is only updated with the master lock held.
+type DeadProc struct {
+	Proc  process.ProcContainer
+	State *os.ProcessState
+}
+
 // ProcWatcher is a wrapper that act as a object that watches a process.
 type ProcWatcher struct {
 	procStatus  chan *ProcStatus
@@ -25,7 +32,7 @@ type ProcWatcher struct {
 // case the process dies at some point.
 type Watcher struct {
 	sync.Mutex
-	restartProc chan process.ProcContainer
+	restartProc chan *DeadProc
 	watchProcs  map[string]*ProcWatcher
 }
 
@@ -33,7 +40,7 @@ type Watcher struct {
 // Returns a Watcher instance.
 func InitWatcher() *Watcher {
 	watcher := &Watcher{
-		restartProc: make(chan process.ProcContainer),
+		restartProc: make(chan *DeadProc),
 		watchProcs:  make(map[string]*ProcWatcher),
 	}
 	return watcher
@@ -42,7 +49,7 @@ func InitWatcher() *Watcher {
 // RestartProc is a wrapper to export the channel restartProc. It basically keeps track of
 // all the processes that died and need to be restarted.
 // Returns a channel with the dead processes that need to be restarted.
-func (watcher *Watcher) RestartProc() chan process.ProcContainer {
+func (watcher *Watcher) RestartProc() chan *DeadProc {
 	return watcher.restartProc
 }
 
@@ -69,12 +76,17 @@ func (watcher *Watcher) AddProcWatcher(proc process.ProcContainer) {
 		}
 	}()
 	go func() {
-		defer delete(watcher.watchProcs, procWatcher.proc.Identifier())
 		select {
 		case procStatus := <-procWatcher.procStatus:
+			// removed before advising master, which watches the proc again once it restarts it
+			if !watcher.removeWatcher(procWatcher) {
+				// stopped meanwhile, StopWatcher waits for the state
+				procWatcher.procStatus <- procStatus
+				break
+			}
 			log.Infof("Proc %s is dead, advising master...", procWatcher.proc.Identifier())
 			log.Infof("State is %s", procStatus.state.String())
-			watcher.restartProc <- procWatcher.proc
+			watcher.restartProc <- &DeadProc{Proc: procWatcher.proc, State: procStatus.state}
 			break
 		case <-procWatcher.stopWatcher:
 			break
@@ -83,17 +95,34 @@ func (watcher *Watcher) AddProcWatcher(proc process.ProcContainer) {
 }
 
 // StopWatcher will stop a running watcher on a process with identifier 'identifier'
-// Returns a channel that will be populated when the watcher is finally done.
-func (watcher *Watcher) StopWatcher(identifier string) chan bool {
-	if watcher, ok := watcher.watchProcs[identifier]; ok {
+// Returns a channel that will be populated with the process state, nil when it could not be read,
+// when the watcher is finally done.
+func (watcher *Watcher) StopWatcher(identifier string) chan *os.ProcessState {
+	watcher.Lock()
+	procWatcher, ok := watcher.watchProcs[identifier]
+	delete(watcher.watchProcs, identifier)
+	watcher.Unlock()
+	if ok {
 		log.Infof("Stopping watcher on proc %s", identifier)
-		watcher.stopWatcher <- true
-		waitStop := make(chan bool, 1)
+		procWatcher.stopWatcher <- true
+		waitStop := make(chan *os.ProcessState, 1)
 		go func() {
-			<-watcher.procStatus
-			waitStop <- true
+			procStatus := <-procWatcher.procStatus
+			waitStop <- procStatus.state
 		}()
 		return waitStop
 	}
 	return nil
 }
+
+// removeWatcher will remove procWatcher.
+// Returns false when it was already removed by StopWatcher.
+func (watcher *Watcher) removeWatcher(procWatcher *ProcWatcher) bool {
+	watcher.Lock()
+	defer watcher.Unlock()
+	if watcher.watchProcs[procWatcher.proc.Identifier()] != procWatcher {
+		return false
+	}
+	delete(watcher.watchProcs, procWatcher.proc.Identifier())
+	return true
+}
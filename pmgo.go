@@ -9,7 +9,7 @@ If you need to use the remote version of PMGO, take a look at RemoteMaster on Ma
 
 To use the remote version of PMGO, use:
 
-- remoteServer := master.StartRemoteMasterServer(dsn, configFile)
+- remoteServer := master.StartRemoteMasterServer(configFile)
 
 It will start a remote master and return the instance.
 
@@ -19,23 +19,34 @@ To make remote requests, use the Remote Client by instantiating using:
 
 It will start the remote client and return the instance so you can use to initiate requests, such as:
 
-- remoteClient.StartGoBin(sourcePath, name, keepAlive, args)
+- remoteClient.StartGoBin(goBin)
 */
 package main
 
 import (
 	"sync"
 
+	"github.com/struCoder/pmgo/lib/build"
+	"github.com/struCoder/pmgo/lib/cgroup"
 	"github.com/struCoder/pmgo/lib/cli"
+	"github.com/struCoder/pmgo/lib/ecosystem"
+	"github.com/struCoder/pmgo/lib/health"
+	"github.com/struCoder/pmgo/lib/install"
+	"github.com/struCoder/pmgo/lib/logrotate"
 	"github.com/struCoder/pmgo/lib/master"
+	"github.com/struCoder/pmgo/lib/process"
+	"github.com/struCoder/pmgo/lib/sourcewatch"
+	"github.com/struCoder/pmgo/lib/utils"
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/sevlyar/go-daemon"
 
 	"os"
 	"os/signal"
+	"os/user"
 	"path"
 	"path/filepath"
+	"strconv"
 	"syscall"
 
 	"fmt"
@@ -47,26 +58,123 @@ import (
 
 var (
 	app     = kingpin.New("pmgo", "Aguia Process Manager.")
-	dns     = app.Flag("dns", "TCP Dns host.").Default(":9876").String()
+	dns     = app.Flag("dns", "TCP address of the daemon to talk to, such as a remote host. Defaults to the unix socket on ~/.pmgo. The daemon listens on TCPAddr of its config.toml.").String()
 	timeout = 30 * time.Second
 
+	token   = app.Flag("token", "Token sent to a daemon listening on TCP.").Envar("PMGO_TOKEN").String()
+	useTLS  = app.Flag("tls", "Connect to the daemon TCP address using TLS.").Envar("PMGO_TLS").Bool()
+	tlsCA   = app.Flag("tls-ca", "CA certificate verifying the daemon, implies --tls.").Envar("PMGO_TLS_CA").String()
+	tlsCert = app.Flag("tls-cert", "Client certificate for daemons requiring mutual TLS, implies --tls.").Envar("PMGO_TLS_CERT").String()
+	tlsKey  = app.Flag("tls-key", "Client certificate key.").Envar("PMGO_TLS_KEY").String()
+
 	serveStop           = app.Command("kill", "Kill daemon pmgo.")
 	serveStopConfigFile = serveStop.Flag("config-file", "Config file location").String()
 
 	serve           = app.Command("serve", "Create pmgo daemon.")
 	serveConfigFile = serve.Flag("config-file", "Config file location").String()
 
-	resurrect = app.Command("resurrect", "Resurrect all previously save processes.")
+	resurrect           = app.Command("resurrect", "Resurrect all previously save processes.")
+	resurrectConfigFile = resurrect.Flag("config-file", "Config file location of the daemon").String()
+
+	startup       = app.Command("startup", "Generate a systemd unit starting pmgo at boot and resurrecting the saved processes.")
+	startupUser   = startup.Flag("user", "Install a systemd user unit instead of a system one.").Bool()
+	startupPrint  = startup.Flag("print", "Only print the unit.").Bool()
+	startupConfig = startup.Flag("serve-config", "Config file location of the daemon, defaults to ~/.pmgo/config.toml.").String()
 
 	start           = app.Command("start", "start and daemonize an app.")
-	startSourcePath = start.Arg("start go file", "go file.").Required().String()
-	startName       = start.Arg("name", "Process name.").Required().String()
+	startSourcePath = start.Arg("start go file", "go file or ecosystem file (.toml, .yaml).").Required().String()
+	startName       = start.Arg("name", "Process name.").String()
 	startKeepAlive  = true
 	startArgs       = start.Flag("args", "External args.").Strings()
+	startEnv        = start.Flag("env", "Environment variable given to the process (KEY=VAL).").StringMap()
+	startEnvFiles   = start.Flag("env-file", "Env file read on every process start.").Strings()
+	startCleanEnv   = start.Flag("clean-env", "Do not inherit the daemon environment.").Bool()
+	startCwd        = start.Flag("cwd", "Process working directory, defaults to the source directory.").String()
+	startLogMaxSize = start.Flag("log-max-size", "Rotate out and err files past this size (ex: 10M).").String()
+	startLogMaxAge  = start.Flag("log-interval", "Rotate out and err files after this long (ex: 24h).").Duration()
+	startLogKeep    = start.Flag("log-keep", "Number of rotated log files kept.").Int()
+	startLogGzip    = start.Flag("log-compress", "Gzip rotated log files.").Bool()
+
+	startRestartDelay    = start.Flag("restart-delay", "Delay before restarting a dead process, doubled on each crash.").Duration()
+	startMaxRestartDelay = start.Flag("max-restart-delay", "Maximum delay between restarts.").Duration()
+	startMinUptime       = start.Flag("min-uptime", "Uptime after which a run is considered stable.").Duration()
+	startRestartJitter   = start.Flag("restart-jitter", "Fraction of the restart delay randomly added or removed, 0 for none.").String()
+	startMaxRestarts     = start.Flag("max-restarts", "Restarts allowed within --restart-window before the process is errored, -1 for unlimited.").String()
+	startRestartWindow   = start.Flag("restart-window", "Period --max-restarts is counted on.").Duration()
+	startStopSignal      = start.Flag("stop-signal", "Signal asking the process to stop (SIGTERM, SIGINT, SIGQUIT, SIGHUP...).").Default("SIGTERM").String()
+	startKillTimeout     = start.Flag("kill-timeout", "Time given to the process to stop before it is killed.").Default("10s").Duration()
+	startInstances       = start.Flag("instances", "Number of instances started on cluster mode, or max for one per CPU.").String()
+	startListen          = start.Flag("listen", "Socket listened by pmgo and inherited by the process ([name=]tcp://host:port or [name=]unix:///path).").Strings()
+
+	startMaxMemory        = start.Flag("max-memory", "Restart the process when its memory stays above this size (ex: 512M).").String()
+	startMaxMemorySamples = start.Flag("max-memory-samples", "Samples in a row, taken every 10s, above --max-memory before a restart (default 3).").Int()
+
+	startCronRestart = start.Flag("cron-restart", "Cron expression the process is restarted on (ex: \"0 4 * * *\", or --cron-restart=@daily for descriptors).").String()
+
+	startWatch         = start.Flag("watch", "Rebuild and restart the process when its .go files, go.mod or go.sum change.").Bool()
+	startWatchIgnore   = start.Flag("watch-ignore", "Glob of files and directories not watched (ex: testdata, *_test.go).").Strings()
+	startWatchDebounce = start.Flag("watch-debounce", "Time the source must stay unchanged before a rebuild (default 500ms).").Duration()
+
+	startRuntime     = start.Flag("runtime", "How the process is started: go builds the source, exec runs a binary and interpreter runs a script.").Default("go").Enum("go", "exec", "interpreter")
+	startInterpreter = start.Flag("interpreter", "Interpreter of the script on the interpreter runtime (ex: python3). Defaults from the script extension.").String()
+	startCopy        = start.Flag("copy", "Copy the binary into the pmgo folder on the exec runtime, instead of running it in place.").Bool()
+
+	startPackage   = start.Flag("package", "Package built, relative to the source directory (ex: ./cmd/server).").String()
+	startTags      = start.Flag("tags", "Build tag of the go runtime.").Strings()
+	startLdflags   = start.Flag("ldflags", "go build -ldflags (ex: --ldflags=\"-X main.version=1.0.0\").").String()
+	startGcflags   = start.Flag("gcflags", "go build -gcflags.").String()
+	startTrimpath  = start.Flag("trimpath", "Build with go build -trimpath.").Bool()
+	startRace      = start.Flag("race", "Build with the race detector.").Bool()
+	startBuildEnv  = start.Flag("build-env", "Environment variable given to go build (ex: CGO_ENABLED=0).").StringMap()
+	startToolchain = start.Flag("toolchain", "go binary building the source, by name or path (ex: go1.22.4).").String()
+
+	startKeepBuilds = start.Flag("keep-builds", "Number of builds kept for pmgo rollback (default 5).").Int()
+
+	startUser   = start.Flag("user", "User, name or uid, the process runs as. Requires a root daemon.").String()
+	startGroup  = start.Flag("group", "Group, name or gid, the process runs as. Defaults to the --user primary group.").String()
+	startGroups = start.Flag("groups", "Supplementary group of the process. Defaults to the --user groups.").Strings()
+
+	startCPUMax    = start.Flag("cpu-max", "CPUs the process may use, enforced with cgroup v2 (ex: 0.5 or 150%).").String()
+	startMemoryMax = start.Flag("memory-max", "Memory limit enforced with cgroup v2, the process is OOM killed above it (ex: 512M).").String()
+	startPidsMax   = start.Flag("pids-max", "Maximum number of processes and threads, enforced with cgroup v2.").Int()
+	startIOWeight  = start.Flag("io-weight", "Proportional IO weight from 1 to 10000, enforced with cgroup v2 (default 100).").Int()
+
+	startHealthHTTP     = start.Flag("health-http", "URL probed with a GET expecting a 2xx status while the process runs.").String()
+	startHealthTCP      = start.Flag("health-tcp", "host:port probed with a TCP connect while the process runs.").String()
+	startHealthExec     = start.Flag("health-exec", "Shell command probed expecting a 0 exit code while the process runs.").String()
+	startHealthInterval = start.Flag("health-interval", "Time between health probes (default 10s).").Duration()
+	startHealthTimeout  = start.Flag("health-timeout", "Time a health probe may take before it fails (default 5s).").Duration()
+	startHealthFailures = start.Flag("health-failures", "Failed health probes in a row after which the process is restarted (default 3).").Int()
 
 	restart     = app.Command("restart", "Restart a process.")
 	restartName = restart.Arg("name", "Process name.").Required().String()
 
+	flush     = app.Command("flush", "Truncate the out and err files of a process.")
+	flushName = flush.Arg("name", "Process name.").Required().String()
+
+	logs        = app.Command("logs", "Display the logs of a process.")
+	logsName    = logs.Arg("name", "Process name or all.").Default("all").String()
+	logsLines   = logs.Flag("lines", "Number of lines displayed from the end of each file.").Default("15").Int()
+	logsFollow  = logs.Flag("follow", "Keep displaying new lines.").Short('f').Bool()
+	logsErrOnly = logs.Flag("err-only", "Only display the err files.").Bool()
+
+	reload     = app.Command("reload", "Start a new instance of a process before stopping the current one.")
+	reloadName = reload.Arg("name", "Process name.").Required().String()
+
+	rebuild     = app.Command("rebuild", "Build a process again from its source and restart it, keeping the running binary on build failures.")
+	rebuildName = rebuild.Arg("name", "Process name.").Required().String()
+
+	history     = app.Command("history", "List the builds kept of a process.")
+	historyName = history.Arg("name", "Process name.").Required().String()
+
+	rollback        = app.Command("rollback", "Switch a process back to a previous build and restart it.")
+	rollbackName    = rollback.Arg("name", "Process name.").Required().String()
+	rollbackVersion = rollback.Arg("version", "Build version, see pmgo history. Defaults to the build before the active one.").String()
+
+	scale          = app.Command("scale", "Add or remove instances of a cluster.")
+	scaleName      = scale.Arg("name", "Cluster name.").Required().String()
+	scaleInstances = scale.Arg("instances", "Number of instances.").Required().Int()
+
 	stop     = app.Command("stop", "Stop a process.")
 	stopName = stop.Arg("name", "Process name.").Required().String()
 
@@ -87,54 +195,323 @@ var (
 func main() {
 	switch kingpin.MustParse(app.Parse(os.Args[1:])) {
 	case serveStop.FullCommand():
+		if *dns != "" {
+			log.Fatal("pmgo kill only stops the daemon of this host, run it without --dns")
+		}
 		checkRemoteMasterServer()
-		cli := cli.InitCli(*dns, timeout)
-		cli.DeleteAllProcess()
 		stopRemoteMasterServer()
 	case serve.FullCommand():
 		log.Warn("Server will auto start and this command will be delete")
 		startRemoteMasterServer()
 	case resurrect.FullCommand():
-		fmt.Println("This feature will not support. sorry")
+		*serveConfigFile = *resurrectConfigFile
+		checkRemoteMasterServer()
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
+		cli.Resurrect()
+		cli.Status()
+	case startup.FullCommand():
+		installStartup()
 	case start.FullCommand():
 		checkRemoteMasterServer()
-		cli := cli.InitCli(*dns, timeout)
-		cli.StartGoBin(*startSourcePath, *startName, startKeepAlive, *startArgs)
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
+		if *startName == "" && ecosystem.IsEcosystemFile(*startSourcePath) {
+			cli.StartEcosystem(*startSourcePath)
+			cli.Status()
+			return
+		}
+		if *startName == "" {
+			app.Fatalf("required argument 'name' not provided")
+		}
+		cli.StartGoBin(&master.GoBin{
+			SourcePath: absPath(*startSourcePath),
+			Name:       *startName,
+			KeepAlive:  startKeepAlive,
+			Args:       *startArgs,
+			Env:        *startEnv,
+			EnvFiles:   absPaths(*startEnvFiles),
+			CleanEnv:   *startCleanEnv,
+			Cwd:        optionalAbsPath(*startCwd),
+			LogRotate:  startLogRotate(),
+
+			Runtime:     *startRuntime,
+			Interpreter: *startInterpreter,
+			Copy:        *startCopy,
+			Build: build.Spec{
+				Package:   *startPackage,
+				Tags:      *startTags,
+				Ldflags:   *startLdflags,
+				Gcflags:   *startGcflags,
+				Trimpath:  *startTrimpath,
+				Race:      *startRace,
+				Env:       *startBuildEnv,
+				Toolchain: *startToolchain,
+			},
+			KeepBuilds: *startKeepBuilds,
+
+			RestartPolicy: startRestartPolicy(),
+			StopSignal:    *startStopSignal,
+			KillTimeout:   *startKillTimeout,
+			Sockets:       *startListen,
+			Instances:     parseInstances(*startInstances),
+
+			MaxMemory:        parseMaxMemory(*startMaxMemory),
+			MaxMemorySamples: *startMaxMemorySamples,
+			CronRestart:      *startCronRestart,
+			SourceWatch: sourcewatch.Config{
+				Enabled:  *startWatch,
+				Ignore:   *startWatchIgnore,
+				Debounce: *startWatchDebounce,
+			},
+
+			User:   *startUser,
+			Group:  *startGroup,
+			Groups: *startGroups,
+			Cgroup: cgroup.Limits{
+				CPUMax:    *startCPUMax,
+				MemoryMax: parseMaxMemory(*startMemoryMax),
+				PidsMax:   *startPidsMax,
+				IOWeight:  *startIOWeight,
+			},
+
+			HealthCheck: health.Check{
+				HTTP:     *startHealthHTTP,
+				TCP:      *startHealthTCP,
+				Exec:     *startHealthExec,
+				Interval: *startHealthInterval,
+				Timeout:  *startHealthTimeout,
+				Failures: *startHealthFailures,
+			},
+		})
 		cli.Status()
 	case restart.FullCommand():
 		checkRemoteMasterServer()
-		cli := cli.InitCli(*dns, timeout)
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
 		cli.RestartProcess(*restartName)
 		cli.Status()
+	case reload.FullCommand():
+		checkRemoteMasterServer()
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
+		cli.ReloadProcess(*reloadName)
+		cli.Status()
+	case rebuild.FullCommand():
+		checkRemoteMasterServer()
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
+		cli.RebuildProcess(*rebuildName)
+		cli.Status()
+	case history.FullCommand():
+		checkRemoteMasterServer()
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
+		cli.History(*historyName)
+	case rollback.FullCommand():
+		checkRemoteMasterServer()
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
+		cli.RollbackProcess(*rollbackName, *rollbackVersion)
+		cli.Status()
+	case scale.FullCommand():
+		checkRemoteMasterServer()
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
+		cli.ScaleProcess(*scaleName, *scaleInstances)
+		cli.Status()
 	case stop.FullCommand():
 		checkRemoteMasterServer()
-		cli := cli.InitCli(*dns, timeout)
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
 		cli.StopProcess(*stopName)
 		cli.Status()
+	case flush.FullCommand():
+		checkRemoteMasterServer()
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
+		cli.FlushProcess(*flushName)
+	case logs.FullCommand():
+		checkRemoteMasterServer()
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
+		cli.Logs(*logsName, *logsLines, *logsFollow, *logsErrOnly)
 	case delete.FullCommand():
 		checkRemoteMasterServer()
-		cli := cli.InitCli(*dns, timeout)
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
 		cli.DeleteProcess(*deleteName)
 	case save.FullCommand():
-		cli := cli.InitCli(*dns, timeout)
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
 		cli.Save()
 	case status.FullCommand():
 		checkRemoteMasterServer()
-		cli := cli.InitCli(*dns, timeout)
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
 		cli.Status()
 	case version.FullCommand():
 		fmt.Println(currentVersion)
 	case info.FullCommand():
 		checkRemoteMasterServer()
-		cli := cli.InitCli(*dns, timeout)
+		cli := cli.InitCli(remoteDsn(), timeout, clientAuth())
 		cli.ProcInfo(*infoName)
 	}
 }
 
+// absPath resolves p against the cli working directory, since the daemon
+// may have been started from anywhere else.
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+// startLogRotate returns the log rotation given on start flags,
+// or nil so the daemon default is used.
+func startLogRotate() *logrotate.Config {
+	if *startLogMaxSize == "" && *startLogMaxAge == 0 && *startLogKeep == 0 && !*startLogGzip {
+		return nil
+	}
+	config := &logrotate.Config{
+		Interval: *startLogMaxAge,
+		Keep:     *startLogKeep,
+		Compress: *startLogGzip,
+	}
+	if *startLogMaxSize != "" {
+		size, err := utils.ParseSize(*startLogMaxSize)
+		if err != nil {
+			app.Fatalf("%s", err)
+		}
+		config.MaxSize = size
+	}
+	return config
+}
+
+// startRestartPolicy returns the restart policy given on start flags,
+// or nil so the daemon default is used.
+func startRestartPolicy() *process.RestartPolicy {
+	policy := &process.RestartPolicy{
+		Delay:     *startRestartDelay,
+		MaxDelay:  *startMaxRestartDelay,
+		MinUptime: *startMinUptime,
+		Window:    *startRestartWindow,
+	}
+	if *startRestartJitter != "" {
+		jitter, err := strconv.ParseFloat(*startRestartJitter, 64)
+		if err != nil {
+			app.Fatalf("--restart-jitter must be a number")
+		}
+		policy.Jitter = &jitter
+	}
+	if *startMaxRestarts != "" {
+		restarts, err := strconv.Atoi(*startMaxRestarts)
+		if err != nil {
+			app.Fatalf("--max-restarts must be a number")
+		}
+		policy.MaxRestarts = &restarts
+	}
+	if *policy == (process.RestartPolicy{}) {
+		return nil
+	}
+	return policy
+}
+
+// installStartup writes, or prints with --print, the systemd unit of the daemon.
+func installStartup() {
+	bin, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to find the pmgo binary due to %s", err)
+	}
+	current, err := user.Current()
+	if err != nil {
+		log.Fatalf("Failed to find the current user due to %s", err)
+	}
+	configFile := *startupConfig
+	if configFile == "" {
+		configFile = path.Join(os.Getenv("HOME"), ".pmgo", "config.toml")
+	}
+	unit := &install.Unit{
+		Bin:        bin,
+		Dsn:        *dns,
+		ConfigFile: absPath(configFile),
+		Home:       os.Getenv("HOME"),
+		User:       current.Username,
+		Path:       os.Getenv("PATH"),
+		UserUnit:   *startupUser,
+		Env:        startupEnv(),
+	}
+	if *startupPrint {
+		content, err := unit.Render()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(content)
+		return
+	}
+	if err := unit.Install(); err != nil {
+		log.Fatalf("Failed to install the systemd unit due to %s", err)
+	}
+	log.Infof("Wrote %s. Enable it with:", unit.File())
+	for _, command := range unit.Commands() {
+		fmt.Println(command)
+	}
+}
+
+// startupEnv returns the PMGO_* settings given to pmgo startup, so the resurrect of the unit
+// authenticates to the daemon TCP address like the cli did.
+func startupEnv() map[string]string {
+	env := map[string]string{}
+	for key, value := range map[string]string{
+		"PMGO_TOKEN":    *token,
+		"PMGO_TLS_CA":   optionalAbsPath(*tlsCA),
+		"PMGO_TLS_CERT": optionalAbsPath(*tlsCert),
+		"PMGO_TLS_KEY":  optionalAbsPath(*tlsKey),
+	} {
+		if value != "" {
+			env[key] = value
+		}
+	}
+	if *useTLS {
+		env["PMGO_TLS"] = "true"
+	}
+	return env
+}
+
+// parseMaxMemory converts the --max-memory and --memory-max flags to bytes, 0 when not set.
+func parseMaxMemory(maxMemory string) int64 {
+	if maxMemory == "" {
+		return 0
+	}
+	size, err := utils.ParseSize(maxMemory)
+	if err != nil {
+		app.Fatalf("%s", err)
+	}
+	return size
+}
+
+// parseInstances converts the --instances flag, where max is one instance per CPU
+// of the daemon host, to the GoBin Instances field.
+func parseInstances(instances string) int {
+	switch instances {
+	case "":
+		return 0
+	case "max":
+		return -1
+	}
+	n, err := strconv.Atoi(instances)
+	if err != nil || n < 1 {
+		app.Fatalf("--instances must be a positive number or max")
+	}
+	return n
+}
+
+func optionalAbsPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	return absPath(p)
+}
+
+func absPaths(paths []string) []string {
+	abs := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs = append(abs, absPath(p))
+	}
+	return abs
+}
+
 func isDaemonRunning(ctx *daemon.Context) (bool, *os.Process, error) {
 	d, err := ctx.Search()
 
-	if err != nil {
+	if err != nil || d == nil {
 		return false, d, err
 	}
 
@@ -146,11 +523,7 @@ func isDaemonRunning(ctx *daemon.Context) (bool, *os.Process, error) {
 }
 
 func getCtx() *daemon.Context {
-	if *serveConfigFile == "" {
-		folderPath := os.Getenv("HOME")
-		*serveConfigFile = folderPath + "/.pmgo/config.toml"
-		os.MkdirAll(path.Dir(*serveConfigFile), 0755)
-	}
+	setDefaultConfigFile()
 
 	ctx := &daemon.Context{
 		PidFileName: path.Join(filepath.Dir(*serveConfigFile), "main.pid"),
@@ -163,8 +536,40 @@ func getCtx() *daemon.Context {
 	return ctx
 }
 
-// if RemoteMasterServer not running, just run
+func setDefaultConfigFile() {
+	if *serveConfigFile == "" {
+		folderPath := os.Getenv("HOME")
+		*serveConfigFile = folderPath + "/.pmgo/config.toml"
+		os.MkdirAll(path.Dir(*serveConfigFile), 0755)
+	}
+}
+
+// remoteDsn returns the --dns TCP address when given, or the daemon unix socket.
+func remoteDsn() string {
+	if *dns != "" {
+		return *dns
+	}
+	setDefaultConfigFile()
+	return master.UnixDsn(master.SocketFile(*serveConfigFile))
+}
+
+// clientAuth returns how the cli authenticates to a daemon listening on TCP.
+func clientAuth() *master.ClientAuth {
+	return &master.ClientAuth{
+		Token:    *token,
+		TLS:      *useTLS,
+		CAFile:   *tlsCA,
+		CertFile: *tlsCert,
+		KeyFile:  *tlsKey,
+	}
+}
+
+// if RemoteMasterServer not running, just run. A daemon given with --dns is managed from afar, so
+// no local one is started then.
 func checkRemoteMasterServer() {
+	if *dns != "" {
+		return
+	}
 	ctx := getCtx()
 	if ok, _, _ := isDaemonRunning(ctx); !ok {
 		startRemoteMasterServer()
@@ -219,7 +624,7 @@ func startRemoteMasterServer() {
 	}
 
 	log.Info("Starting remote master server...")
-	remoteMaster := master.StartRemoteMasterServer(*dns, *serveConfigFile)
+	remoteMaster := master.StartRemoteMasterServer(*serveConfigFile)
 
 	// send signal to parent's process to kill goroutine
 	kill(os.Getppid(), syscall.SIGUSR1)